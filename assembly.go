@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// pageDeadline bounds all the upstream calls made to assemble one page.
+	pageDeadline = 15 * time.Second
+	// slowCallThreshold is how long an upstream call may take before it's logged as slow.
+	slowCallThreshold = time.Second
+)
+
+// pageCall is one independent upstream fetch that contributes to a page. Run stores its
+// result through a closure. A failing Optional call is logged and leaves its part of the page
+// empty; a failing required call fails the whole page.
+type pageCall struct {
+	Name     string
+	Optional bool
+	Run      func(ctx context.Context) error
+}
+
+// withPageDeadline derives the shared deadline the calls assembling a page run under.
+func withPageDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, pageDeadline)
+}
+
+// assemblePage runs calls concurrently, logging how long each took, and returns the first
+// error from a required call. Once a required call fails the others are cancelled.
+func assemblePage(ctx context.Context, page string, calls ...pageCall) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, call := range calls {
+		wg.Add(1)
+		go func(call pageCall) {
+			defer wg.Done()
+			start := time.Now()
+			err := call.Run(ctx)
+			elapsed := time.Since(start)
+			switch {
+			case err != nil:
+				log.Printf("DEBUG: %s - %s failed after %v: %v", page, call.Name, elapsed, err)
+			case elapsed >= slowCallThreshold:
+				log.Printf("DEBUG: %s - %s slow: %v", page, call.Name, elapsed)
+			default:
+				log.Printf("DEBUG: %s - %s took %v", page, call.Name, elapsed)
+			}
+			if err == nil || call.Optional {
+				return
+			}
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			mu.Unlock()
+		}(call)
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -100,6 +100,10 @@ func NewSQLiteStore(dbPath string, key []byte) (*sqliteStore, error) {
 						state TEXT PRIMARY KEY,
 						data BLOB,
 						updated_at INTEGER
+					);`, `CREATE TABLE IF NOT EXISTS timeline_filters(
+						did TEXT PRIMARY KEY,
+						data TEXT,
+						updated_at INTEGER
 					);`}
 	for _, s := range schema {
 		if _, err := db.Exec(s); err != nil {
@@ -185,3 +189,30 @@ func (s *sqliteStore) DeleteAuthRequestInfo(ctx context.Context, state string) e
 	_, err := s.db.ExecContext(ctx, `DELETE FROM auth_requests WHERE state = ?`, state)
 	return err
 }
+
+// GetTimelineFilters returns the timeline filters saved for did, or the zero value (no
+// filtering) when there are none.
+func (s *sqliteStore) GetTimelineFilters(ctx context.Context, did string) (TimelineFilters, error) {
+	var out TimelineFilters
+	row := s.db.QueryRowContext(ctx, `SELECT data FROM timeline_filters WHERE did = ?`, did)
+	var data string
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return out, nil
+		}
+		return out, err
+	}
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		return TimelineFilters{}, err
+	}
+	return out, nil
+}
+
+func (s *sqliteStore) SaveTimelineFilters(ctx context.Context, did string, f TimelineFilters) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO timeline_filters(did, data, updated_at) VALUES (?, ?, ?) ON CONFLICT(did) DO UPDATE SET data=excluded.data, updated_at=excluded.updated_at`, did, string(data), time.Now().Unix())
+	return err
+}
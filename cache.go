@@ -0,0 +1,306 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+// cacheKey identifies a cached appview response. Viewer is the DID of the account the response
+// was fetched as (empty for unauthenticated clients), since post and profile views carry
+// viewer state such as likes and follows. Limit distinguishes follows pages of different sizes.
+type cacheKey struct {
+	Viewer  string
+	Subject string
+	Limit   int64
+}
+
+// viewerOf returns the DID the client is authenticated as, or "" for public clients.
+func viewerOf(c *client.APIClient) string {
+	if c == nil || c.AccountDID == nil {
+		return ""
+	}
+	return c.AccountDID.String()
+}
+
+// lruCache is a size-bounded LRU cache whose entries expire after a TTL. Concurrent misses for
+// the same key share a single fetch. Hits and misses are counted for /debug/cache.
+type lruCache[K comparable, V any] struct {
+	name    string
+	size    int
+	ttl     time.Duration
+	hits    atomic.Int64
+	misses  atomic.Int64
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[K]*list.Element
+	flights flightGroup[K, V]
+	// gen is bumped by every invalidation so fetches that started before it don't store
+	// their (possibly stale) results.
+	gen uint64
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// flightTimeout bounds a shared fetch. It runs detached from the requests waiting on it, so
+// one client going away doesn't fail the fetch for the others.
+const flightTimeout = 10 * time.Second
+
+// flightGroup collapses concurrent calls for the same key into one.
+type flightGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flightCall[V]
+}
+
+type flightCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Do runs fn for key unless a call for key is already in flight, and waits for the result.
+// fn gets a context detached from ctx with flightTimeout applied; ctx only bounds how long
+// this caller waits. A panic in fn is returned to every waiter as an error.
+func (g *flightGroup[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (V, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		if g.calls == nil {
+			g.calls = map[K]*flightCall[V]{}
+		}
+		call = &flightCall[V]{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.run(context.WithoutCancel(ctx), key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (g *flightGroup[K, V]) run(ctx context.Context, key K, call *flightCall[V], fn func(ctx context.Context) (V, error)) {
+	ctx, cancel := context.WithTimeout(ctx, flightTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("DEBUG: flightGroup - panic fetching %v: %v\n%s", key, r, debug.Stack())
+			call.err = fmt.Errorf("panic: %v", r)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = fn(ctx)
+}
+
+func newLRUCache[K comparable, V any](name string, size int, ttl time.Duration) *lruCache[K, V] {
+	cache := &lruCache[K, V]{
+		name:    name,
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: map[K]*list.Element{},
+	}
+	allCaches = append(allCaches, cache)
+	return cache
+}
+
+// Peek returns the cached value for key without fetching it, counting a hit or a miss.
+func (lc *lruCache[K, V]) Peek(key K) (V, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if v, ok := lc.lookup(key); ok {
+		lc.hits.Add(1)
+		return v, true
+	}
+	lc.misses.Add(1)
+	var zero V
+	return zero, false
+}
+
+// Get returns the cached value for key, calling fetch on a miss. Errors are not cached. fetch
+// runs under flightGroup.Do, so it must use the context it is given.
+func (lc *lruCache[K, V]) Get(ctx context.Context, key K, fetch func(ctx context.Context) (V, error)) (V, error) {
+	lc.mu.Lock()
+	if v, ok := lc.lookup(key); ok {
+		lc.hits.Add(1)
+		lc.mu.Unlock()
+		return v, nil
+	}
+	lc.misses.Add(1)
+	lc.mu.Unlock()
+	return lc.flights.Do(ctx, key, func(ctx context.Context) (V, error) {
+		gen := lc.generation()
+		v, err := fetch(ctx)
+		if err == nil {
+			lc.storeIfCurrent(gen, key, v)
+		}
+		return v, err
+	})
+}
+
+// generation returns the current invalidation generation, to be passed to storeIfCurrent.
+func (lc *lruCache[K, V]) generation() uint64 {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.gen
+}
+
+// storeIfCurrent stores value under key unless the cache was invalidated since gen was read.
+func (lc *lruCache[K, V]) storeIfCurrent(gen uint64, key K, value V) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if gen == lc.gen {
+		lc.store(key, value)
+	}
+}
+
+// DeleteFunc removes every entry whose key matches, and keeps in-flight fetches from
+// storing their results.
+func (lc *lruCache[K, V]) DeleteFunc(match func(K) bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.gen++
+	for key, el := range lc.entries {
+		if match(key) {
+			lc.order.Remove(el)
+			delete(lc.entries, key)
+		}
+	}
+}
+
+// lookup returns the live entry for key, dropping it if it has expired. lc.mu must be held.
+func (lc *lruCache[K, V]) lookup(key K) (V, bool) {
+	var zero V
+	el, ok := lc.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*cacheEntry[K, V])
+	if time.Now().After(entry.expires) {
+		lc.order.Remove(el)
+		delete(lc.entries, key)
+		return zero, false
+	}
+	lc.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// store inserts or refreshes key and evicts the least recently used entries over size.
+// lc.mu must be held.
+func (lc *lruCache[K, V]) store(key K, value V) {
+	expires := time.Now().Add(lc.ttl)
+	if el, ok := lc.entries[key]; ok {
+		entry := el.Value.(*cacheEntry[K, V])
+		entry.value, entry.expires = value, expires
+		lc.order.MoveToFront(el)
+		return
+	}
+	lc.entries[key] = lc.order.PushFront(&cacheEntry[K, V]{key: key, value: value, expires: expires})
+	for lc.order.Len() > lc.size {
+		oldest := lc.order.Back()
+		lc.order.Remove(oldest)
+		delete(lc.entries, oldest.Value.(*cacheEntry[K, V]).key)
+	}
+}
+
+func (lc *lruCache[K, V]) writeStats(w io.Writer) {
+	lc.mu.Lock()
+	n := len(lc.entries)
+	lc.mu.Unlock()
+	fmt.Fprintf(w, "%s entries=%d hits=%d misses=%d\n", lc.name, n, lc.hits.Load(), lc.misses.Load())
+}
+
+// allCaches lists every cache so /debug/cache can report on them.
+var allCaches []interface{ writeStats(io.Writer) }
+
+// Shared appview caches. Post, profile and follows views depend on who is looking, so they
+// are keyed by viewer; handle resolution isn't.
+var (
+	postCache    = newLRUCache[cacheKey, *bsky.FeedDefs_PostView]("posts", 5000, time.Minute)
+	profileCache = newLRUCache[cacheKey, *bsky.ActorDefs_ProfileViewDetailed]("profiles", 1000, 2*time.Minute)
+	followsCache = newLRUCache[cacheKey, []*bsky.ActorDefs_ProfileView]("follows", 500, 2*time.Minute)
+	handleCache  = newLRUCache[string, string]("handles", 5000, 10*time.Minute)
+)
+
+// postBatchFlights collapses concurrent getPosts calls for the same viewer and URIs.
+var postBatchFlights flightGroup[cacheKey, map[string]*bsky.FeedDefs_PostView]
+
+// cachedPostsBatch returns the posts for uris, serving what it can from postCache and fetching
+// the rest with fetch in one call.
+func cachedPostsBatch(ctx context.Context, c *client.APIClient, uris []string, fetch func(context.Context, []string) (map[string]*bsky.FeedDefs_PostView, error)) (map[string]*bsky.FeedDefs_PostView, error) {
+	viewer := viewerOf(c)
+	m := make(map[string]*bsky.FeedDefs_PostView, len(uris))
+	var missing []string
+	for _, uri := range uris {
+		if pv, ok := postCache.Peek(cacheKey{Viewer: viewer, Subject: uri}); ok {
+			m[uri] = pv
+		} else {
+			missing = append(missing, uri)
+		}
+	}
+	if len(missing) == 0 {
+		return m, nil
+	}
+	sorted := append([]string(nil), missing...)
+	sort.Strings(sorted)
+	fetched, err := postBatchFlights.Do(ctx, cacheKey{Viewer: viewer, Subject: strings.Join(sorted, " ")}, func(ctx context.Context) (map[string]*bsky.FeedDefs_PostView, error) {
+		gen := postCache.generation()
+		fetched, err := fetch(ctx, missing)
+		for uri, pv := range fetched {
+			postCache.storeIfCurrent(gen, cacheKey{Viewer: viewer, Subject: uri}, pv)
+		}
+		return fetched, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for uri, pv := range fetched {
+		m[uri] = pv
+	}
+	return m, nil
+}
+
+// invalidatePost drops every viewer's cached view of a post whose counts or viewer state
+// just changed.
+func invalidatePost(uri string) {
+	postCache.DeleteFunc(func(k cacheKey) bool { return k.Subject == uri })
+}
+
+// invalidateProfile drops every viewer's cached view of a profile and of its follows.
+func invalidateProfile(did string) {
+	match := func(k cacheKey) bool { return k.Subject == did }
+	profileCache.DeleteFunc(match)
+	followsCache.DeleteFunc(match)
+}
+
+// handleCacheStats reports entry counts and hit/miss counters for the shared caches. It is
+// only routed when TUITER_DEBUG is set.
+func handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, cache := range allCaches {
+		cache.writeStats(w)
+	}
+}
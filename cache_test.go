@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupSharesOneCall(t *testing.T) {
+	var g flightGroup[string, int]
+	release := make(chan struct{})
+	calls := 0
+	fn := func(ctx context.Context) (int, error) {
+		calls++
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = g.Do(context.Background(), "k", fn)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("fn ran %d times, want 1", calls)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("caller %d got %d", i, v)
+		}
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	var g flightGroup[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+	errs := make(chan error, 2)
+	go func() {
+		_, err := g.Do(context.Background(), "k", func(ctx context.Context) (int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := g.Do(context.Background(), "k", func(ctx context.Context) (int, error) { return 1, nil })
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for range 2 {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Error("waiter got no error from a panicking fetch")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("waiter hung after the fetch panicked")
+		}
+	}
+	// the key is free again
+	if v, err := g.Do(context.Background(), "k", func(ctx context.Context) (int, error) { return 7, nil }); v != 7 || err != nil {
+		t.Errorf("after panic: got %d, %v", v, err)
+	}
+}
+
+func TestFlightGroupCallerCancel(t *testing.T) {
+	var g flightGroup[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var fnErr error
+	fn := func(ctx context.Context) (int, error) {
+		close(started)
+		if _, ok := ctx.Deadline(); !ok {
+			fnErr = errors.New("shared fetch has no deadline")
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-release:
+			return 42, nil
+		}
+	}
+
+	// the first caller goes away while its fetch is shared with a second one
+	first, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := g.Do(first, "k", fn)
+		firstErr <- err
+	}()
+	<-started
+	second := make(chan int, 1)
+	go func() {
+		v, _ := g.Do(context.Background(), "k", fn)
+		second <- v
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancelFirst()
+	select {
+	case err := <-firstErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("cancelled caller got %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelled caller kept waiting")
+	}
+
+	close(release)
+	select {
+	case v := <-second:
+		if v != 42 {
+			t.Errorf("remaining caller got %d, want 42", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("remaining caller hung")
+	}
+	if fnErr != nil {
+		t.Error(fnErr)
+	}
+}
+
+func TestLRUCacheGet(t *testing.T) {
+	lc := newLRUCache[string, int]("test", 2, time.Minute)
+	ctx := context.Background()
+	fetches := 0
+	fetch := func(v int) func(context.Context) (int, error) {
+		return func(context.Context) (int, error) { fetches++; return v, nil }
+	}
+
+	lc.Get(ctx, "a", fetch(1))
+	lc.Get(ctx, "b", fetch(2))
+	if v, _ := lc.Get(ctx, "a", fetch(99)); v != 1 {
+		t.Errorf("a = %d, want cached 1", v)
+	}
+	// "b" is now least recently used and makes room for "c"
+	lc.Get(ctx, "c", fetch(3))
+	if _, ok := lc.Peek("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if fetches != 3 {
+		t.Errorf("fetched %d times, want 3", fetches)
+	}
+
+	if _, err := lc.Get(ctx, "d", func(context.Context) (int, error) { return 0, errors.New("down") }); err == nil {
+		t.Error("expected the fetch error")
+	}
+	if _, ok := lc.Peek("d"); ok {
+		t.Error("errors must not be cached")
+	}
+}
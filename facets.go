@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+// Rich-text facets annotate byte ranges of a post's UTF-8 text with mentions, links and tags.
+// On write, parseFacetSpans finds candidates in the text and detectFacets resolves them into
+// app.bsky.richtext.facet values. On read, renderFacetedText turns the facets of a record into
+// escaped HTML with links to profiles, external URLs and tag pages.
+
+var (
+	// handles must have at least one dot, labels can't start or end with a dash
+	facetMentionRe = regexp.MustCompile(`(?:^|[\s(])(@([a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+))`)
+	facetLinkRe    = regexp.MustCompile(`(?:^|[\s(])(https?://[^\s]+)`)
+	facetTagRe     = regexp.MustCompile(`(?:^|\s)([#＃][^\s#＃]+)`)
+)
+
+// maxTagLength is the longest tag (in runes, without the leading #) accepted by Bluesky.
+const maxTagLength = 64
+
+type facetKind int
+
+const (
+	facetMention facetKind = iota
+	facetLink
+	facetTag
+)
+
+// facetSpan is a facet candidate found in post text. Start and End are UTF-8 byte offsets
+// covering the visible text (including the leading @ or #). Value holds the handle, URL or tag.
+type facetSpan struct {
+	Kind  facetKind
+	Start int
+	End   int
+	Value string
+}
+
+// parseFacetSpans finds mentions, links and tags in text without any network access.
+// Spans are returned ordered by Start and never overlap.
+func parseFacetSpans(text string) []facetSpan {
+	var spans []facetSpan
+
+	for _, m := range facetMentionRe.FindAllStringSubmatchIndex(text, -1) {
+		spans = append(spans, facetSpan{Kind: facetMention, Start: m[2], End: m[3], Value: strings.ToLower(text[m[4]:m[5]])})
+	}
+
+	for _, m := range facetLinkRe.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2], m[3]
+		end = start + len(trimLinkSuffix(text[start:end]))
+		if _, err := url.ParseRequestURI(text[start:end]); err != nil {
+			continue
+		}
+		spans = append(spans, facetSpan{Kind: facetLink, Start: start, End: end, Value: text[start:end]})
+	}
+
+	for _, m := range facetTagRe.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2], m[3]
+		_, hashSize := utf8.DecodeRuneInString(text[start:end])
+		tag := strings.TrimRightFunc(text[start+hashSize:end], unicode.IsPunct)
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || isAllDigits(tag) {
+			continue
+		}
+		spans = append(spans, facetSpan{Kind: facetTag, Start: start, End: start + hashSize + len(tag), Value: tag})
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	out := spans[:0]
+	lastEnd := -1
+	for _, sp := range spans {
+		if sp.Start < lastEnd {
+			continue
+		}
+		out = append(out, sp)
+		lastEnd = sp.End
+	}
+	return out
+}
+
+// trimLinkSuffix drops trailing punctuation that is usually part of the sentence rather than
+// the URL. A closing paren is only kept when it balances an opening one inside the URL.
+func trimLinkSuffix(link string) string {
+	for len(link) > 0 {
+		last := link[len(link)-1]
+		if strings.IndexByte(`.,;:!?"'`, last) >= 0 {
+			link = link[:len(link)-1]
+			continue
+		}
+		if last == ')' && strings.Count(link, ")") > strings.Count(link, "(") {
+			link = link[:len(link)-1]
+			continue
+		}
+		break
+	}
+	return link
+}
+
+func isAllDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectFacets builds the richtext facets for a post being written. Mentions are resolved to
+// DIDs; mentions of handles that don't resolve are left as plain text.
+func detectFacets(ctx context.Context, c *client.APIClient, text string) []*bsky.RichtextFacet {
+	var facets []*bsky.RichtextFacet
+	for _, sp := range parseFacetSpans(text) {
+		feature := &bsky.RichtextFacet_Features_Elem{}
+		switch sp.Kind {
+		case facetMention:
+			did, err := resolveHandleToDID(ctx, c, sp.Value)
+			if err != nil {
+				log.Printf("DEBUG: detectFacets - unable to resolve mention @%s: %v", sp.Value, err)
+				continue
+			}
+			feature.RichtextFacet_Mention = &bsky.RichtextFacet_Mention{Did: did}
+		case facetLink:
+			feature.RichtextFacet_Link = &bsky.RichtextFacet_Link{Uri: sp.Value}
+		case facetTag:
+			feature.RichtextFacet_Tag = &bsky.RichtextFacet_Tag{Tag: sp.Value}
+		}
+		facets = append(facets, &bsky.RichtextFacet{
+			Index:    &bsky.RichtextFacet_ByteSlice{ByteStart: int64(sp.Start), ByteEnd: int64(sp.End)},
+			Features: []*bsky.RichtextFacet_Features_Elem{feature},
+		})
+	}
+	return facets
+}
+
+// renderPostText is the template helper for post bodies: it returns the record's text as
+// escaped HTML with its facets turned into links.
+func renderPostText(record *util.LexiconTypeDecoder) template.HTML {
+	if record != nil {
+		if post, ok := record.Val.(*bsky.FeedPost); ok && post != nil {
+			return renderFacetedText(post.Text, post.Facets)
+		}
+	}
+	return template.HTML(template.HTMLEscapeString(getPostText(record)))
+}
+
+// renderFacetedText escapes text and wraps each valid facet range in a link. Facets with
+// out-of-range offsets, offsets splitting a UTF-8 sequence, overlapping ranges or unsupported
+// link schemes are ignored and their text rendered plainly.
+func renderFacetedText(text string, facets []*bsky.RichtextFacet) template.HTML {
+	type ranged struct {
+		start, end int
+		href       string
+		class      string
+		external   bool
+	}
+	var ranges []ranged
+	for _, f := range facets {
+		if f == nil || f.Index == nil {
+			continue
+		}
+		start, end := int(f.Index.ByteStart), int(f.Index.ByteEnd)
+		if start < 0 || end > len(text) || start >= end {
+			continue
+		}
+		if !utf8.RuneStart(text[start]) || (end < len(text) && !utf8.RuneStart(text[end])) {
+			continue
+		}
+		r := ranged{start: start, end: end}
+		for _, feat := range f.Features {
+			if feat == nil {
+				continue
+			}
+			switch {
+			case feat.RichtextFacet_Mention != nil && feat.RichtextFacet_Mention.Did != "":
+				r.href = "/profile/" + url.PathEscape(feat.RichtextFacet_Mention.Did)
+				r.class = "mention"
+			case feat.RichtextFacet_Link != nil && isSafeLink(feat.RichtextFacet_Link.Uri):
+				r.href = feat.RichtextFacet_Link.Uri
+				r.class = "link"
+				r.external = true
+			case feat.RichtextFacet_Tag != nil && feat.RichtextFacet_Tag.Tag != "":
+				r.href = tagURL(feat.RichtextFacet_Tag.Tag)
+				r.class = "hashtag"
+			}
+			if r.href != "" {
+				break
+			}
+		}
+		if r.href == "" {
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+
+	var b strings.Builder
+	pos := 0
+	for _, r := range ranges {
+		if r.start < pos {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(text[pos:r.start]))
+		b.WriteString(`<a href="`)
+		b.WriteString(template.HTMLEscapeString(r.href))
+		b.WriteString(`" class="`)
+		b.WriteString(r.class)
+		b.WriteString(`"`)
+		if r.external {
+			b.WriteString(` target="_blank" rel="noopener nofollow"`)
+		}
+		b.WriteString(`>`)
+		b.WriteString(template.HTMLEscapeString(text[r.start:r.end]))
+		b.WriteString(`</a>`)
+		pos = r.end
+	}
+	b.WriteString(template.HTMLEscapeString(text[pos:]))
+	return template.HTML(b.String())
+}
+
+// isSafeLink reports whether a link facet URI may be rendered as an href.
+func isSafeLink(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return (scheme == "http" || scheme == "https") && u.Host != ""
+}
+
+// tagURL returns the local tag page for a hashtag (without its leading #).
+func tagURL(tag string) string {
+	return "/tag/" + url.PathEscape(tag)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+)
+
+func TestParseFacetSpansByteOffsets(t *testing.T) {
+	type want struct {
+		kind  facetKind
+		text  string // exact slice of the post text the span must cover
+		value string
+	}
+	tests := []struct {
+		name string
+		text string
+		want []want
+	}{
+		{
+			name: "ascii",
+			text: "hi @alice.bsky.social see https://example.com #go",
+			want: []want{
+				{facetMention, "@alice.bsky.social", "alice.bsky.social"},
+				{facetLink, "https://example.com", "https://example.com"},
+				{facetTag, "#go", "go"},
+			},
+		},
+		{
+			name: "emoji before everything",
+			text: "🦋🦋 @bob.test 👋 https://example.com/ü 🎉 #日本",
+			want: []want{
+				{facetMention, "@bob.test", "bob.test"},
+				{facetLink, "https://example.com/ü", "https://example.com/ü"},
+				{facetTag, "#日本", "日本"},
+			},
+		},
+		{
+			name: "cjk around facets",
+			text: "こんにちは @carol.test さん #東京 は https://例え.jp です",
+			want: []want{
+				{facetMention, "@carol.test", "carol.test"},
+				{facetTag, "#東京", "東京"},
+				{facetLink, "https://例え.jp", "https://例え.jp"},
+			},
+		},
+		{
+			name: "emoji after facets",
+			text: "@dave.test🎉 nope, @dave.test 🎉 #tag🎉",
+			want: []want{
+				{facetMention, "@dave.test", "dave.test"},
+				{facetMention, "@dave.test", "dave.test"},
+				{facetTag, "#tag🎉", "tag🎉"},
+			},
+		},
+		{
+			name: "trailing punctuation on links",
+			text: "see https://example.com/a. and (https://example.com/b) or https://en.wikipedia.org/wiki/Go_(language)!",
+			want: []want{
+				{facetLink, "https://example.com/a", "https://example.com/a"},
+				{facetLink, "https://example.com/b", "https://example.com/b"},
+				{facetLink, "https://en.wikipedia.org/wiki/Go_(language)", "https://en.wikipedia.org/wiki/Go_(language)"},
+			},
+		},
+		{
+			name: "trailing punctuation on tags and full-width hash",
+			text: "ok ＃ゴー, #done!",
+			want: []want{
+				{facetTag, "＃ゴー", "ゴー"},
+				{facetTag, "#done", "done"},
+			},
+		},
+		{
+			name: "not facets",
+			text: "mail a@b.test, #123, @nodot and ftp://example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := parseFacetSpans(tt.text)
+			if len(spans) != len(tt.want) {
+				t.Fatalf("got %d spans %+v, want %d", len(spans), spans, len(tt.want))
+			}
+			from := 0
+			for i, sp := range spans {
+				w := tt.want[i]
+				start := from + strings.Index(tt.text[from:], w.text)
+				if sp.Kind != w.kind || sp.Start != start || sp.End != start+len(w.text) || sp.Value != w.value {
+					t.Errorf("span %d = %+v, want kind %d bytes [%d,%d) value %q", i, sp, w.kind, start, start+len(w.text), w.value)
+				}
+				if got := tt.text[sp.Start:sp.End]; got != w.text {
+					t.Errorf("span %d covers %q, want %q", i, got, w.text)
+				}
+				from = sp.End
+			}
+		})
+	}
+}
+
+func TestParseFacetSpansAbsoluteOffsets(t *testing.T) {
+	// 🦋 is 4 bytes and é is 2, so the mention starts at byte 8 while being rune 4
+	spans := parseFacetSpans("🦋 é @a.test")
+	if len(spans) != 1 || spans[0].Start != 8 || spans[0].End != 15 {
+		t.Fatalf("got %+v, want one span at bytes [8,15)", spans)
+	}
+}
+
+func facet(start, end int, feature *bsky.RichtextFacet_Features_Elem) *bsky.RichtextFacet {
+	return &bsky.RichtextFacet{
+		Index:    &bsky.RichtextFacet_ByteSlice{ByteStart: int64(start), ByteEnd: int64(end)},
+		Features: []*bsky.RichtextFacet_Features_Elem{feature},
+	}
+}
+
+func linkFeature(uri string) *bsky.RichtextFacet_Features_Elem {
+	return &bsky.RichtextFacet_Features_Elem{RichtextFacet_Link: &bsky.RichtextFacet_Link{Uri: uri}}
+}
+
+func TestRenderFacetedText(t *testing.T) {
+	mention := &bsky.RichtextFacet_Features_Elem{RichtextFacet_Mention: &bsky.RichtextFacet_Mention{Did: "did:plc:abc"}}
+	tag := &bsky.RichtextFacet_Features_Elem{RichtextFacet_Tag: &bsky.RichtextFacet_Tag{Tag: "日本"}}
+	tests := []struct {
+		name   string
+		text   string
+		facets []*bsky.RichtextFacet
+		want   string
+	}{
+		{
+			name:   "mention after emoji",
+			text:   "🦋 @a.test hi",
+			facets: []*bsky.RichtextFacet{facet(5, 12, mention)},
+			want:   `🦋 <a href="/profile/did:plc:abc" class="mention">@a.test</a> hi`,
+		},
+		{
+			name:   "cjk tag and link",
+			text:   "東京 #日本 https://example.com",
+			facets: []*bsky.RichtextFacet{facet(7, 14, tag), facet(15, 34, linkFeature("https://example.com"))},
+			want:   `東京 <a href="/tag/%E6%97%A5%E6%9C%AC" class="hashtag">#日本</a> <a href="https://example.com" class="link" target="_blank" rel="noopener nofollow">https://example.com</a>`,
+		},
+		{
+			name:   "text is escaped",
+			text:   "<b>@a.test</b>",
+			facets: []*bsky.RichtextFacet{facet(3, 10, mention)},
+			want:   `&lt;b&gt;<a href="/profile/did:plc:abc" class="mention">@a.test</a>&lt;/b&gt;`,
+		},
+		{
+			name:   "end past text",
+			text:   "@a.test",
+			facets: []*bsky.RichtextFacet{facet(0, 8, mention)},
+			want:   `@a.test`,
+		},
+		{
+			name:   "negative start",
+			text:   "@a.test",
+			facets: []*bsky.RichtextFacet{facet(-1, 7, mention)},
+			want:   `@a.test`,
+		},
+		{
+			name:   "empty range",
+			text:   "@a.test",
+			facets: []*bsky.RichtextFacet{facet(3, 3, mention)},
+			want:   `@a.test`,
+		},
+		{
+			name:   "start mid rune",
+			text:   "🦋@a.test",
+			facets: []*bsky.RichtextFacet{facet(2, 11, mention)},
+			want:   `🦋@a.test`,
+		},
+		{
+			name:   "end mid rune",
+			text:   "@a🦋 x",
+			facets: []*bsky.RichtextFacet{facet(0, 4, mention)},
+			want:   `@a🦋 x`,
+		},
+		{
+			name:   "javascript link",
+			text:   "click me",
+			facets: []*bsky.RichtextFacet{facet(0, 8, linkFeature("javascript:alert(1)"))},
+			want:   `click me`,
+		},
+		{
+			name:   "mixed case javascript link",
+			text:   "click me",
+			facets: []*bsky.RichtextFacet{facet(0, 8, linkFeature("JavaScript://example.com/%0Aalert(1)"))},
+			want:   `click me`,
+		},
+		{
+			name:   "link href is attribute-escaped",
+			text:   "x",
+			facets: []*bsky.RichtextFacet{facet(0, 1, linkFeature(`https://example.com/"onmouseover="x`))},
+			want:   `<a href="https://example.com/&#34;onmouseover=&#34;x" class="link" target="_blank" rel="noopener nofollow">x</a>`,
+		},
+		{
+			name:   "overlapping facets keep the first",
+			text:   "@a.test",
+			facets: []*bsky.RichtextFacet{facet(0, 7, mention), facet(2, 7, tag)},
+			want:   `<a href="/profile/did:plc:abc" class="mention">@a.test</a>`,
+		},
+		{
+			name:   "nil facet and index",
+			text:   "plain",
+			facets: []*bsky.RichtextFacet{nil, {}},
+			want:   `plain`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(renderFacetedText(tt.text, tt.facets)); got != tt.want {
+				t.Errorf("renderFacetedText() =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseThenRenderRoundTrip(t *testing.T) {
+	text := "🎉 #東京 https://example.com/ü."
+	var facets []*bsky.RichtextFacet
+	for _, sp := range parseFacetSpans(text) {
+		switch sp.Kind {
+		case facetLink:
+			facets = append(facets, facet(sp.Start, sp.End, linkFeature(sp.Value)))
+		case facetTag:
+			facets = append(facets, facet(sp.Start, sp.End, &bsky.RichtextFacet_Features_Elem{RichtextFacet_Tag: &bsky.RichtextFacet_Tag{Tag: sp.Value}}))
+		}
+	}
+	got := string(renderFacetedText(text, facets))
+	want := `🎉 <a href="/tag/%E6%9D%B1%E4%BA%AC" class="hashtag">#東京</a> <a href="https://example.com/ü" class="link" target="_blank" rel="noopener nofollow">https://example.com/ü</a>.`
+	if got != want {
+		t.Errorf("got\n  %s\nwant\n  %s", got, want)
+	}
+}
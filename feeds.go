@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+// Custom feeds are app.bsky.feed.generator records read through app.bsky.feed.getFeed. The
+// feeds a user saved, and which of them are pinned, live in the savedFeedsPrefV2 entry of
+// their app.bsky.actor preferences.
+
+const (
+	feedGeneratorCollection = "app.bsky.feed.generator"
+	savedFeedsPrefType      = "app.bsky.actor.defs#savedFeedsPref"
+	savedFeedsPrefV2Type    = "app.bsky.actor.defs#savedFeedsPrefV2"
+)
+
+// feedGeneratorURI returns the at:// URI of a feed generator record.
+func feedGeneratorURI(did, rkey string) string {
+	return "at://" + did + "/" + feedGeneratorCollection + "/" + rkey
+}
+
+// feedPageURL returns the local /feed/{did}/{rkey} page of a feed generator URI, or "" for
+// anything else.
+func feedPageURL(uri string) string {
+	u, err := syntax.ParseATURI(uri)
+	if err != nil || u.Collection().String() != feedGeneratorCollection || u.RecordKey() == "" {
+		return ""
+	}
+	return "/feed/" + u.Authority().String() + "/" + u.RecordKey().String()
+}
+
+// rawPreferences is the getPreferences/putPreferences body with each preference kept as raw
+// JSON. The generated union in the bsky package drops preference types it doesn't know, so
+// writing typed preferences back could erase settings made by newer clients.
+type rawPreferences struct {
+	Preferences []json.RawMessage `json:"preferences"`
+}
+
+func getRawPreferences(ctx context.Context, c *client.APIClient) ([]json.RawMessage, error) {
+	var out rawPreferences
+	if err := c.LexDo(ctx, util.Query, "", "app.bsky.actor.getPreferences", nil, nil, &out); err != nil {
+		return nil, fmt.Errorf("getPreferences error: %w", err)
+	}
+	return out.Preferences, nil
+}
+
+func putRawPreferences(ctx context.Context, c *client.APIClient, prefs []json.RawMessage) error {
+	if err := c.LexDo(ctx, util.Procedure, "application/json", "app.bsky.actor.putPreferences", nil, rawPreferences{Preferences: prefs}, nil); err != nil {
+		return fmt.Errorf("putPreferences error: %w", err)
+	}
+	return nil
+}
+
+func preferenceType(raw json.RawMessage) string {
+	var head struct {
+		Type string `json:"$type"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return ""
+	}
+	return head.Type
+}
+
+// savedFeedsFromPreferences returns the saved feeds in a preferences list. Accounts that only
+// have the older savedFeedsPref get it converted to v2 items.
+func savedFeedsFromPreferences(prefs []json.RawMessage) []*bsky.ActorDefs_SavedFeed {
+	var v1 *bsky.ActorDefs_SavedFeedsPref
+	for _, raw := range prefs {
+		switch preferenceType(raw) {
+		case savedFeedsPrefV2Type:
+			var p bsky.ActorDefs_SavedFeedsPrefV2
+			if err := json.Unmarshal(raw, &p); err == nil {
+				return p.Items
+			}
+		case savedFeedsPrefType:
+			var p bsky.ActorDefs_SavedFeedsPref
+			if err := json.Unmarshal(raw, &p); err == nil {
+				v1 = &p
+			}
+		}
+	}
+	if v1 == nil {
+		return nil
+	}
+
+	pinned := map[string]bool{}
+	for _, uri := range v1.Pinned {
+		pinned[uri] = true
+	}
+	var items []*bsky.ActorDefs_SavedFeed
+	for _, uri := range v1.Saved {
+		u, err := syntax.ParseATURI(uri)
+		if err != nil {
+			continue
+		}
+		typ := "feed"
+		if u.Collection().String() == "app.bsky.graph.list" {
+			typ = "list"
+		}
+		items = append(items, &bsky.ActorDefs_SavedFeed{Id: syntax.NewTIDNow(0).String(), Type: typ, Value: uri, Pinned: pinned[uri]})
+	}
+	return items
+}
+
+// withSavedFeeds returns prefs with its savedFeedsPrefV2 entry replaced by items, leaving every
+// other preference untouched.
+func withSavedFeeds(prefs []json.RawMessage, items []*bsky.ActorDefs_SavedFeed) ([]json.RawMessage, error) {
+	if items == nil {
+		items = []*bsky.ActorDefs_SavedFeed{}
+	}
+	raw, err := json.Marshal(&bsky.ActorDefs_SavedFeedsPrefV2{LexiconTypeID: savedFeedsPrefV2Type, Items: items})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]json.RawMessage, 0, len(prefs)+1)
+	replaced := false
+	for _, p := range prefs {
+		if preferenceType(p) == savedFeedsPrefV2Type {
+			if !replaced {
+				out = append(out, raw)
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, p)
+	}
+	if !replaced {
+		out = append(out, raw)
+	}
+	return out, nil
+}
+
+// loadSavedFeeds fetches the signed-in user's saved feeds.
+func loadSavedFeeds(ctx context.Context, c *client.APIClient) ([]*bsky.ActorDefs_SavedFeed, error) {
+	prefs, err := getRawPreferences(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return savedFeedsFromPreferences(prefs), nil
+}
+
+// updateSavedFeeds applies a save/remove/pin/unpin action to a feed and writes the
+// preferences back, returning the new saved feeds.
+func updateSavedFeeds(ctx context.Context, c *client.APIClient, uri, action string) ([]*bsky.ActorDefs_SavedFeed, error) {
+	prefs, err := getRawPreferences(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	items, err := applySavedFeedAction(savedFeedsFromPreferences(prefs), uri, action)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err = withSavedFeeds(prefs, items)
+	if err != nil {
+		return nil, err
+	}
+	if err := putRawPreferences(ctx, c, prefs); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// applySavedFeedAction returns items after saving, removing, pinning or unpinning the feed
+// generator uri. Pinning an unsaved feed saves it too.
+func applySavedFeedAction(items []*bsky.ActorDefs_SavedFeed, uri, action string) ([]*bsky.ActorDefs_SavedFeed, error) {
+	idx := -1
+	for i, it := range items {
+		if it != nil && it.Type == "feed" && it.Value == uri {
+			idx = i
+			break
+		}
+	}
+	switch action {
+	case "save", "pin":
+		if idx < 0 {
+			items = append(items, &bsky.ActorDefs_SavedFeed{Id: syntax.NewTIDNow(0).String(), Type: "feed", Value: uri})
+			idx = len(items) - 1
+		}
+		if action == "pin" {
+			items[idx].Pinned = true
+		}
+	case "unpin":
+		if idx >= 0 {
+			items[idx].Pinned = false
+		}
+	case "remove":
+		if idx >= 0 {
+			items = append(items[:idx:idx], items[idx+1:]...)
+		}
+	default:
+		return nil, fmt.Errorf("unknown feed action %q", action)
+	}
+	return items, nil
+}
+
+// FeedControlsVM is the view model of a feed's save and pin buttons.
+type FeedControlsVM struct {
+	URI    string
+	Saved  bool
+	Pinned bool
+}
+
+// feedControls returns the controls for the feed generator uri given the saved feeds.
+func feedControls(items []*bsky.ActorDefs_SavedFeed, uri string) FeedControlsVM {
+	vm := FeedControlsVM{URI: uri}
+	for _, it := range items {
+		if it != nil && it.Type == "feed" && it.Value == uri {
+			vm.Saved, vm.Pinned = true, it.Pinned
+		}
+	}
+	return vm
+}
+
+// FeedTab is a tab above the timeline: the home timeline or a pinned feed.
+type FeedTab struct {
+	Name   string
+	URL    string
+	Active bool
+}
+
+// pinnedFeedURIs returns the pinned feed generators in the order the user pinned them.
+func pinnedFeedURIs(items []*bsky.ActorDefs_SavedFeed) []string {
+	var uris []string
+	for _, it := range items {
+		if it != nil && it.Pinned && it.Type == "feed" && feedPageURL(it.Value) != "" {
+			uris = append(uris, it.Value)
+		}
+	}
+	return uris
+}
+
+// fetchFeedGenerators returns the views of the feed generators uris in one
+// getFeedGenerators call, keyed by URI. Generators that can't be described are left out.
+func fetchFeedGenerators(ctx context.Context, c *client.APIClient, uris []string) map[string]*bsky.FeedDefs_GeneratorView {
+	gens := map[string]*bsky.FeedDefs_GeneratorView{}
+	if len(uris) == 0 {
+		return gens
+	}
+	out, err := bsky.FeedGetFeedGenerators(ctx, c, uris)
+	if err != nil {
+		log.Printf("DEBUG: fetchFeedGenerators - getFeedGenerators error: %v", err)
+		return gens
+	}
+	for _, g := range out.Feeds {
+		if g != nil {
+			gens[g.Uri] = g
+		}
+	}
+	return gens
+}
+
+// feedTabs returns the home timeline tab followed by the pinned feed generators, in the order
+// the user pinned them. activeURI is the feed being viewed ("" for the home timeline); gens
+// are the generator views fetched for pinnedFeedURIs.
+func feedTabs(items []*bsky.ActorDefs_SavedFeed, activeURI string, gens map[string]*bsky.FeedDefs_GeneratorView) []FeedTab {
+	tabs := []FeedTab{{Name: "Archive", URL: "/timeline", Active: activeURI == ""}}
+	for _, uri := range pinnedFeedURIs(items) {
+		var name string
+		if g := gens[uri]; g != nil {
+			name = g.DisplayName
+		}
+		if name == "" {
+			// unknown or offline generators still get a tab, named by their record key
+			name = syntax.ATURI(uri).RecordKey().String()
+		}
+		tabs = append(tabs, FeedTab{Name: name, URL: feedPageURL(uri), Active: uri == activeURI})
+	}
+	return tabs
+}
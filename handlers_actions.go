@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+// handleFav likes or unlikes a post for the signed-in user. The button sends the state it
+// wants (action=like|unlike) along with the like record it knows of, so a second click that
+// lands before the appview has indexed the first one can't create a duplicate like: an
+// existing like is checked against the PDS before a new app.bsky.feed.like record is created.
+// Responds with a re-rendered fav_button fragment for htmx to swap in.
+func handleFav(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.FormValue("uri")
+	if uri == "" {
+		http.Error(w, "uri is required", http.StatusBadRequest)
+		return
+	}
+	action := r.FormValue("action")
+	if action != "like" && action != "unlike" {
+		http.Error(w, "action must be like or unlike", http.StatusBadRequest)
+		return
+	}
+
+	pv, err := fetchPost(r.Context(), c, uri)
+	if err != nil {
+		log.Printf("DEBUG: handleFav - error fetching post %s: %v", uri, err)
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	likeURI := r.FormValue("like")
+	if likeURI == "" && getIsFav(pv) {
+		likeURI = *pv.Viewer.Like
+	}
+	if action == "like" && likeURI != "" {
+		live, err := liveRecord(r.Context(), c, didStr, likeURI)
+		if err != nil {
+			log.Printf("DEBUG: handleFav - error checking like %s: %v", likeURI, err)
+			http.Error(w, "Failed to fav: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		likeURI = live
+	}
+	switch {
+	case action == "unlike" && likeURI != "":
+		rkey, err := recordKeyFromURI(likeURI)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := atproto.RepoDeleteRecord(r.Context(), c, &atproto.RepoDeleteRecord_Input{
+			Collection: "app.bsky.feed.like",
+			Repo:       didStr,
+			Rkey:       rkey,
+		}); err != nil {
+			log.Printf("DEBUG: handleFav - error deleting like %s: %v", likeURI, err)
+			http.Error(w, "Failed to unfav: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		likeURI = ""
+	case action == "like" && likeURI == "":
+		like := &bsky.FeedLike{
+			CreatedAt: syntax.DatetimeNow().String(),
+			Subject:   &atproto.RepoStrongRef{Uri: pv.Uri, Cid: pv.Cid},
+		}
+		resp, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
+			Collection: "app.bsky.feed.like",
+			Repo:       didStr,
+			Record:     &util.LexiconTypeDecoder{Val: like},
+		})
+		if err != nil {
+			log.Printf("DEBUG: handleFav - error creating like for %s: %v", uri, err)
+			http.Error(w, "Failed to fav: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		likeURI = resp.Uri
+	}
+	invalidatePost(uri)
+
+	isFav := likeURI != ""
+	count := adjustCount(getLikeCount(pv), getIsFav(pv), isFav)
+	w.Header().Set("Content-Type", "text/html")
+	data := map[string]interface{}{"Class": r.FormValue("class"), "Count": count, "IsFav": isFav, "Like": likeURI, "Uri": uri}
+	if err := tpl.ExecuteTemplate(w, "fav_button", data); err != nil {
+		log.Printf("DEBUG: handleFav - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// handleRetweet reposts or un-reposts a post for the signed-in user. Like handleFav, the button
+// sends the state it wants (action=repost|unrepost) and the repost record it knows of, and an
+// existing repost is checked against the PDS before a new app.bsky.feed.repost record is
+// created. Responds with a re-rendered rt_button fragment. When a new repost is made from the
+// timeline, the reposted item is also prepended to #timeline-posts via an out-of-band swap so it
+// shows up without waiting for the appview to index it.
+func handleRetweet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.FormValue("uri")
+	if uri == "" {
+		http.Error(w, "uri is required", http.StatusBadRequest)
+		return
+	}
+	action := r.FormValue("action")
+	if action != "repost" && action != "unrepost" {
+		http.Error(w, "action must be repost or unrepost", http.StatusBadRequest)
+		return
+	}
+
+	pv, err := fetchPost(r.Context(), c, uri)
+	if err != nil {
+		log.Printf("DEBUG: handleRetweet - error fetching post %s: %v", uri, err)
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	repostURI := r.FormValue("repost")
+	if repostURI == "" && getIsRt(pv) {
+		repostURI = *pv.Viewer.Repost
+	}
+	if action == "repost" && repostURI != "" {
+		live, err := liveRecord(r.Context(), c, didStr, repostURI)
+		if err != nil {
+			log.Printf("DEBUG: handleRetweet - error checking repost %s: %v", repostURI, err)
+			http.Error(w, "Failed to retweet: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		repostURI = live
+	}
+	created := false
+	switch {
+	case action == "unrepost" && repostURI != "":
+		rkey, err := recordKeyFromURI(repostURI)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := atproto.RepoDeleteRecord(r.Context(), c, &atproto.RepoDeleteRecord_Input{
+			Collection: "app.bsky.feed.repost",
+			Repo:       didStr,
+			Rkey:       rkey,
+		}); err != nil {
+			log.Printf("DEBUG: handleRetweet - error deleting repost %s: %v", repostURI, err)
+			http.Error(w, "Failed to un-retweet: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		repostURI = ""
+	case action == "repost" && repostURI == "":
+		repost := &bsky.FeedRepost{
+			CreatedAt: syntax.DatetimeNow().String(),
+			Subject:   &atproto.RepoStrongRef{Uri: pv.Uri, Cid: pv.Cid},
+		}
+		resp, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
+			Collection: "app.bsky.feed.repost",
+			Repo:       didStr,
+			Record:     &util.LexiconTypeDecoder{Val: repost},
+		})
+		if err != nil {
+			log.Printf("DEBUG: handleRetweet - error creating repost for %s: %v", uri, err)
+			http.Error(w, "Failed to retweet: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		repostURI = resp.Uri
+		created = true
+	}
+	invalidatePost(uri)
+
+	isRt := repostURI != ""
+	count := adjustCount(getRepostCount(pv), getIsRt(pv), isRt)
+	w.Header().Set("Content-Type", "text/html")
+	data := map[string]interface{}{"Class": r.FormValue("class"), "Count": count, "IsRt": isRt, "Repost": repostURI, "Uri": uri}
+	if err := tpl.ExecuteTemplate(w, "rt_button", data); err != nil {
+		log.Printf("DEBUG: handleRetweet - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Only the timeline lists the viewer's own reposts, so skip the prepend elsewhere.
+	if !created || !strings.Contains(r.Header.Get("HX-Current-URL"), "/timeline") {
+		return
+	}
+	me, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		log.Printf("DEBUG: handleRetweet - error fetching signed-in profile: %v", err)
+		return
+	}
+	// pv may be shared through postCache, so update a copy
+	reposted := *pv
+	countVal := int64(count)
+	reposted.RepostCount = &countVal
+	viewer := bsky.FeedDefs_ViewerState{}
+	if pv.Viewer != nil {
+		viewer = *pv.Viewer
+	}
+	viewer.Repost = &repostURI
+	reposted.Viewer = &viewer
+	item := &bsky.FeedDefs_FeedViewPost{
+		Post: &reposted,
+		Reason: &bsky.FeedDefs_FeedViewPost_Reason{FeedDefs_ReasonRepost: &bsky.FeedDefs_ReasonRepost{
+			By:        &bsky.ActorDefs_ProfileViewBasic{Did: me.Did, Handle: me.Handle, DisplayName: me.DisplayName, Avatar: me.Avatar},
+			IndexedAt: syntax.DatetimeNow().String(),
+			Uri:       &repostURI,
+		}},
+	}
+	fmt.Fprint(w, `<div hx-swap-oob="afterbegin:#timeline-posts">`)
+	if err := tpl.ExecuteTemplate(w, "post_item", map[string]interface{}{"Post": item, "PostsList": PostsList{ViewerDid: didStr}}); err != nil {
+		log.Printf("DEBUG: handleRetweet - failed to render reposted item: %v", err)
+	}
+	fmt.Fprint(w, `</div>`)
+}
+
+// handleDelete deletes one of the signed-in user's posts. The rkey is taken from the post's
+// at:// URI, which must belong to the signed-in repo. Responds with an empty body so the htmx
+// outerHTML swap removes the item; when the deleted post is the one being viewed (viewed=1)
+// the client is redirected to its parent, or to the author's profile for top-level posts.
+func handleDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.FormValue("uri")
+	aturi, err := syntax.ParseATURI(uri)
+	if err != nil {
+		http.Error(w, "invalid uri", http.StatusBadRequest)
+		return
+	}
+	if aturi.Authority().String() != didStr || aturi.Collection().String() != "app.bsky.feed.post" {
+		http.Error(w, "You can only delete your own posts", http.StatusForbidden)
+		return
+	}
+	rkey := aturi.RecordKey().String()
+	if rkey == "" {
+		http.Error(w, "invalid uri", http.StatusBadRequest)
+		return
+	}
+
+	// work out where to go before the post is gone
+	redirect := ""
+	if r.FormValue("viewed") != "" {
+		redirect = "/timeline"
+		if pv, err := fetchPost(r.Context(), c, uri); err == nil {
+			redirect = getProfileURL(pv.Author)
+			if parentURI := extractReplyParentURI(pv); parentURI != "" {
+				if parent, err := fetchPost(r.Context(), c, parentURI); err == nil {
+					redirect = getPostURL(parent)
+				}
+			}
+		}
+	}
+
+	if _, err := atproto.RepoDeleteRecord(r.Context(), c, &atproto.RepoDeleteRecord_Input{
+		Collection: "app.bsky.feed.post",
+		Repo:       didStr,
+		Rkey:       rkey,
+	}); err != nil {
+		log.Printf("DEBUG: handleDelete - error deleting %s: %v", uri, err)
+		http.Error(w, "Failed to delete: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Println("Deleted post:", uri)
+	invalidatePost(uri)
+	invalidateProfile(didStr)
+
+	if redirect != "" {
+		if r.Header.Get("HX-Request") == "" {
+			http.Redirect(w, r, redirect, http.StatusFound)
+			return
+		}
+		w.Header().Set("HX-Redirect", redirect)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleFollow follows or unfollows a profile for the signed-in user. Like handleFav, the button
+// sends the state it wants (action=follow|unfollow) and the follow record it knows of, and an
+// existing follow is checked against the PDS before a new app.bsky.graph.follow record is
+// created. Responds with the re-rendered follow_button for the clicked place, plus out-of-band
+// updates for the other places the page reports having (places) and for the follower count
+// when it's shown (count).
+func handleFollow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	did := r.FormValue("did")
+	if did == "" || did == didStr {
+		http.Error(w, "invalid profile", http.StatusBadRequest)
+		return
+	}
+	action := r.FormValue("action")
+	if action != "follow" && action != "unfollow" {
+		http.Error(w, "action must be follow or unfollow", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, did)
+	if err != nil {
+		log.Printf("DEBUG: handleFollow - error fetching profile %s: %v", did, err)
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+	vm := followButton(profile, r.FormValue("place"))
+	if vm == nil {
+		http.Error(w, "Profile has no viewer state", http.StatusInternalServerError)
+		return
+	}
+
+	wasFollowing := vm.Following
+	followURI := r.FormValue("follow")
+	if followURI == "" {
+		followURI = vm.FollowURI
+	}
+	if action == "follow" && followURI != "" {
+		live, err := liveRecord(r.Context(), c, didStr, followURI)
+		if err != nil {
+			log.Printf("DEBUG: handleFollow - error checking follow %s: %v", followURI, err)
+			http.Error(w, "Failed to follow: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		followURI = live
+	}
+	switch {
+	case action == "unfollow" && followURI != "":
+		rkey, err := recordKeyFromURI(followURI)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := atproto.RepoDeleteRecord(r.Context(), c, &atproto.RepoDeleteRecord_Input{
+			Collection: "app.bsky.graph.follow",
+			Repo:       didStr,
+			Rkey:       rkey,
+		}); err != nil {
+			log.Printf("DEBUG: handleFollow - error deleting follow %s: %v", followURI, err)
+			http.Error(w, "Failed to unfollow: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		followURI = ""
+	case action == "follow" && followURI == "":
+		follow := &bsky.GraphFollow{
+			CreatedAt: syntax.DatetimeNow().String(),
+			Subject:   profile.Did,
+		}
+		resp, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
+			Collection: "app.bsky.graph.follow",
+			Repo:       didStr,
+			Record:     &util.LexiconTypeDecoder{Val: follow},
+		})
+		if err != nil {
+			log.Printf("DEBUG: handleFollow - error creating follow for %s: %v", did, err)
+			http.Error(w, "Failed to follow: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		followURI = resp.Uri
+	}
+	vm.Following, vm.FollowURI = followURI != "", followURI
+	count := adjustCount(getFollowersCount(profile), wasFollowing, vm.Following)
+	invalidateProfile(profile.Did)
+	invalidateProfile(didStr)
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "follow_button", vm); err != nil {
+		log.Printf("DEBUG: handleFollow - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// the page lists the other copies of the button it shows (see initFollowButtons), so
+	// out-of-band swaps are only sent for elements that exist
+	for _, place := range strings.Split(r.FormValue("places"), ",") {
+		if place == vm.Place || !slices.Contains(followPlaces, place) {
+			continue
+		}
+		other := *vm
+		other.Place, other.OOB = place, true
+		if err := tpl.ExecuteTemplate(w, "follow_button", &other); err != nil {
+			log.Printf("DEBUG: handleFollow - failed to render %s button: %v", place, err)
+		}
+	}
+	if r.FormValue("count") != "" {
+		fmt.Fprintf(w, `<strong id="%s" hx-swap-oob="true">%d</strong>`, followersCountID(profile.Did), count)
+	}
+}
+
+// liveRecord returns uri if the record still exists in the signed-in repo, or "" when it has
+// been deleted. Viewer state from the appview lags behind writes, so a like, repost or follow
+// it reports is checked here before an action treats it as already done.
+func liveRecord(ctx context.Context, c *client.APIClient, repo, uri string) (string, error) {
+	aturi, err := syntax.ParseATURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if aturi.Authority().String() != repo {
+		return "", fmt.Errorf("record %s is not in %s", uri, repo)
+	}
+	_, err = atproto.RepoGetRecord(ctx, c, "", aturi.Collection().String(), repo, aturi.RecordKey().String())
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && apiErr.Name == "RecordNotFound" {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return uri, nil
+}
+
+// adjustCount moves a count the appview reported while the viewer's record existed (was) to
+// what it should show now that it does or doesn't (is).
+func adjustCount(count int, was, is bool) int {
+	switch {
+	case was && !is && count > 0:
+		return count - 1
+	case !was && is:
+		return count + 1
+	}
+	return count
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/auth/oauth"
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+// fakePDS is an httptest server standing in for the signed-in user's PDS and the appview
+// behind it. records holds the record URIs that exist; viewer is the viewer state the
+// appview reports for posts, which tests set stale on purpose.
+type fakePDS struct {
+	*httptest.Server
+	mu      sync.Mutex
+	records map[string]bool
+	viewer  map[string]any
+	count   int
+	created []string
+	deleted []string
+}
+
+func newFakePDS(t *testing.T) *fakePDS {
+	f := &fakePDS{records: map[string]bool{}, viewer: map[string]any{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakePDS) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+	switch r.URL.Path {
+	case "/xrpc/app.bsky.feed.getPosts":
+		posts := []map[string]any{}
+		for _, uri := range q["uris"] {
+			posts = append(posts, map[string]any{
+				"uri":       uri,
+				"cid":       "bafyreib2rxk3rh6kzwq",
+				"author":    map[string]any{"did": "did:plc:author", "handle": "author.test"},
+				"record":    map[string]any{"$type": "app.bsky.feed.post", "text": "hi", "createdAt": "2025-01-01T00:00:00Z"},
+				"indexedAt": "2025-01-01T00:00:00Z",
+				"likeCount": f.count, "repostCount": f.count,
+				"viewer": f.viewer,
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"posts": posts})
+	case "/xrpc/app.bsky.actor.getProfile":
+		json.NewEncoder(w).Encode(map[string]any{"did": q.Get("actor"), "handle": "someone.test", "followersCount": f.count, "viewer": f.viewer})
+	case "/xrpc/com.atproto.repo.getRecord":
+		uri := fmt.Sprintf("at://%s/%s/%s", q.Get("repo"), q.Get("collection"), q.Get("rkey"))
+		if !f.records[uri] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "RecordNotFound", "message": "Could not locate record"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"uri": uri, "value": map[string]any{"$type": q.Get("collection"), "createdAt": "2025-01-01T00:00:00Z"}})
+	case "/xrpc/com.atproto.repo.createRecord":
+		var in struct{ Repo, Collection string }
+		json.NewDecoder(r.Body).Decode(&in)
+		uri := fmt.Sprintf("at://%s/%s/%d", in.Repo, in.Collection, time.Now().UnixNano())
+		f.records[uri] = true
+		f.created = append(f.created, uri)
+		json.NewEncoder(w).Encode(map[string]any{"uri": uri, "cid": "bafyreib2rxk3rh6kzwq"})
+	case "/xrpc/com.atproto.repo.deleteRecord":
+		var in struct{ Repo, Collection, Rkey string }
+		json.NewDecoder(r.Body).Decode(&in)
+		uri := fmt.Sprintf("at://%s/%s/%s", in.Repo, in.Collection, in.Rkey)
+		delete(f.records, uri)
+		f.deleted = append(f.deleted, uri)
+		json.NewEncoder(w).Encode(map[string]any{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// signedInAt is signedIn with a session whose PDS is host.
+func signedInAt(t *testing.T, r *http.Request, host string) *http.Request {
+	t.Helper()
+	setupHandlers(t)
+	key, err := crypto.GeneratePrivateKeyP256()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "session-" + host
+	err = oauthApp.Store.SaveSession(context.Background(), oauth.ClientSessionData{
+		AccountDID:              syntax.DID(testDID),
+		SessionID:               sessionID,
+		HostURL:                 host,
+		DPoPPrivateKeyMultibase: key.Multibase(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	session, _ := store.Get(httptest.NewRequest(http.MethodGet, "/", nil), sessionName)
+	session.Values["did"] = testDID
+	session.Values["session_id"] = sessionID
+	if err := session.Save(r, rec); err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func TestHandleFav(t *testing.T) {
+	like := "at://" + testDID + "/app.bsky.feed.like/3kabc"
+	tests := []struct {
+		name       string
+		action     string
+		form       string // extra query, e.g. the like the button knows of
+		viewerLike bool   // the appview reports like as the viewer's like
+		liked      bool   // like exists on the PDS
+		wantCreate bool
+		wantDelete bool
+		wantActive bool
+		wantCount  int
+	}{
+		{name: "like", action: "like", wantCreate: true, wantActive: true, wantCount: 4},
+		{name: "like already indexed", action: "like", viewerLike: true, liked: true, wantActive: true, wantCount: 3},
+		{name: "like after an unlike not yet indexed", action: "like", viewerLike: true, wantCreate: true, wantActive: true, wantCount: 3},
+		{name: "unlike before the like is indexed", action: "unlike", form: "&like=" + like, liked: true, wantDelete: true, wantCount: 3},
+		{name: "unlike", action: "unlike", viewerLike: true, liked: true, wantDelete: true, wantCount: 2},
+		{name: "unlike already gone", action: "unlike", wantCount: 3},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pds := newFakePDS(t)
+			pds.count = 3
+			if tt.viewerLike {
+				pds.viewer["like"] = like
+			}
+			pds.records[like] = tt.liked
+			uri := fmt.Sprintf("at://did:plc:author/app.bsky.feed.post/fav%d-%d", i, time.Now().UnixNano())
+
+			r := httptest.NewRequest(http.MethodPost, "/fav?uri="+uri+"&action="+tt.action+tt.form, nil)
+			w := httptest.NewRecorder()
+			handleFav(w, signedInAt(t, r, pds.URL))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if got := len(pds.created) > 0; got != tt.wantCreate {
+				t.Errorf("created %v, want create %v", pds.created, tt.wantCreate)
+			}
+			if got := len(pds.deleted) > 0; got != tt.wantDelete {
+				t.Errorf("deleted %v, want delete %v", pds.deleted, tt.wantDelete)
+			}
+			body := w.Body.String()
+			if got := strings.Contains(body, "action=unlike"); got != tt.wantActive {
+				t.Errorf("button active = %v, want %v:\n%s", got, tt.wantActive, body)
+			}
+			if !strings.Contains(body, fmt.Sprintf(`<span class="fav-count">%d</span>`, tt.wantCount)) {
+				t.Errorf("want count %d:\n%s", tt.wantCount, body)
+			}
+		})
+	}
+
+	t.Run("unknown action", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/fav?uri=at://did:plc:author/app.bsky.feed.post/1", nil)
+		w := httptest.NewRecorder()
+		handleFav(w, signedIn(t, r))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status %d, want 400", w.Code)
+		}
+	})
+}
+
+func TestHandleRetweet(t *testing.T) {
+	repost := "at://" + testDID + "/app.bsky.feed.repost/3kabc"
+	tests := []struct {
+		name         string
+		action       string
+		form         string
+		viewerRepost bool
+		reposted     bool
+		wantCreate   bool
+		wantDelete   bool
+		wantActive   bool
+	}{
+		{name: "repost", action: "repost", wantCreate: true, wantActive: true},
+		{name: "repost already indexed", action: "repost", viewerRepost: true, reposted: true, wantActive: true},
+		{name: "repost after an unrepost not yet indexed", action: "repost", viewerRepost: true, wantCreate: true, wantActive: true},
+		{name: "unrepost before the repost is indexed", action: "unrepost", form: "&repost=" + repost, reposted: true, wantDelete: true},
+		{name: "unrepost already gone", action: "unrepost"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pds := newFakePDS(t)
+			if tt.viewerRepost {
+				pds.viewer["repost"] = repost
+			}
+			pds.records[repost] = tt.reposted
+			uri := fmt.Sprintf("at://did:plc:author/app.bsky.feed.post/rt%d-%d", i, time.Now().UnixNano())
+
+			r := httptest.NewRequest(http.MethodPost, "/rt?uri="+uri+"&action="+tt.action+tt.form, nil)
+			r.Header.Set("HX-Current-URL", "http://localhost/timeline")
+			w := httptest.NewRecorder()
+			handleRetweet(w, signedInAt(t, r, pds.URL))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if got := len(pds.created) > 0; got != tt.wantCreate {
+				t.Errorf("created %v, want create %v", pds.created, tt.wantCreate)
+			}
+			if got := len(pds.deleted) > 0; got != tt.wantDelete {
+				t.Errorf("deleted %v, want delete %v", pds.deleted, tt.wantDelete)
+			}
+			body := w.Body.String()
+			if got := strings.Contains(body, "action=unrepost"); got != tt.wantActive {
+				t.Errorf("button active = %v, want %v:\n%s", got, tt.wantActive, body)
+			}
+			// only a new repost is prepended, and its button knows the record to undo
+			prepended := strings.Contains(body, "afterbegin:#timeline-posts")
+			if prepended != tt.wantCreate {
+				t.Errorf("prepended = %v, want %v", prepended, tt.wantCreate)
+			}
+			if prepended && strings.Count(body, "repost="+url.QueryEscape(pds.created[0])) != 2 {
+				t.Errorf("buttons don't carry the new repost %s:\n%s", pds.created[0], body)
+			}
+		})
+	}
+}
+
+func TestHandleFollow(t *testing.T) {
+	follow := "at://" + testDID + "/app.bsky.graph.follow/3kabc"
+	tests := []struct {
+		name            string
+		action          string
+		form            string
+		viewerFollowing bool
+		following       bool
+		wantCreate      bool
+		wantDelete      bool
+		wantActive      bool
+		wantCount       int
+	}{
+		{name: "follow", action: "follow", wantCreate: true, wantActive: true, wantCount: 4},
+		{name: "follow already indexed", action: "follow", viewerFollowing: true, following: true, wantActive: true, wantCount: 3},
+		{name: "follow after an unfollow not yet indexed", action: "follow", viewerFollowing: true, wantCreate: true, wantActive: true, wantCount: 3},
+		{name: "unfollow before the follow is indexed", action: "unfollow", form: "&follow=" + follow, following: true, wantDelete: true, wantCount: 3},
+		{name: "unfollow already gone", action: "unfollow", wantCount: 3},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pds := newFakePDS(t)
+			pds.count = 3
+			if tt.viewerFollowing {
+				pds.viewer["following"] = follow
+			}
+			pds.records[follow] = tt.following
+			did := fmt.Sprintf("did:plc:follow%d%d", i, time.Now().UnixNano())
+
+			r := httptest.NewRequest(http.MethodPost, "/follow?did="+did+"&place=profile&count=1&action="+tt.action+tt.form, nil)
+			w := httptest.NewRecorder()
+			handleFollow(w, signedInAt(t, r, pds.URL))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if got := len(pds.created) > 0; got != tt.wantCreate {
+				t.Errorf("created %v, want create %v", pds.created, tt.wantCreate)
+			}
+			if got := len(pds.deleted) > 0; got != tt.wantDelete {
+				t.Errorf("deleted %v, want delete %v", pds.deleted, tt.wantDelete)
+			}
+			body := w.Body.String()
+			if got := strings.Contains(body, "action=unfollow"); got != tt.wantActive {
+				t.Errorf("button active = %v, want %v:\n%s", got, tt.wantActive, body)
+			}
+			if tt.wantCreate && !strings.Contains(body, "follow="+url.QueryEscape(pds.created[0])) {
+				t.Errorf("button doesn't carry the new follow %s:\n%s", pds.created[0], body)
+			}
+			if !strings.Contains(body, fmt.Sprintf(`hx-swap-oob="true">%d</strong>`, tt.wantCount)) {
+				t.Errorf("want follower count %d:\n%s", tt.wantCount, body)
+			}
+		})
+	}
+}
+
+func TestThreadDeleteLinks(t *testing.T) {
+	setupHandlers(t)
+	post := func(did, rkey string) *bsky.FeedDefs_PostView {
+		return &bsky.FeedDefs_PostView{
+			Uri:    "at://" + did + "/app.bsky.feed.post/" + rkey,
+			Author: &bsky.ActorDefs_ProfileViewBasic{Did: did, Handle: "someone.test"},
+			Record: &util.LexiconTypeDecoder{Val: &bsky.FeedPost{Text: "hi"}},
+		}
+	}
+	mine, theirs := post(testDID, "mine"), post("did:plc:other", "theirs")
+	deleteLink := func(p *bsky.FeedDefs_PostView, target string) string {
+		return `hx-post="/delete?uri=` + url.QueryEscape(p.Uri) + `"` + "\n  " +
+			`hx-confirm="Sure you want to delete this update? There is NO undo!"` + "\n  " +
+			`hx-target="` + target + `"`
+	}
+
+	for _, viewer := range []string{testDID, ""} {
+		var sb strings.Builder
+		node := &bsky.FeedDefs_ThreadViewPost{Post: theirs, Replies: []*bsky.FeedDefs_ThreadViewPost_Replies_Elem{
+			{FeedDefs_ThreadViewPost: &bsky.FeedDefs_ThreadViewPost{Post: mine}},
+		}}
+		if err := tpl.ExecuteTemplate(&sb, "thread_node", wrapThread(node, "", viewer)); err != nil {
+			t.Fatal(err)
+		}
+		chain := []ThreadItem{{Post: mine}, {Post: theirs}}
+		if err := tpl.ExecuteTemplate(&sb, "conversation_chain", map[string]any{"Items": chain, "ViewerDid": viewer}); err != nil {
+			t.Fatal(err)
+		}
+		body := sb.String()
+		for _, tt := range []struct {
+			post   *bsky.FeedDefs_PostView
+			target string
+			want   bool
+		}{
+			{mine, "closest .thread-node", viewer != ""},
+			{mine, "closest .chain-item", viewer != ""},
+			{theirs, "closest .thread-node", false},
+			{theirs, "closest .chain-item", false},
+		} {
+			if got := strings.Contains(body, deleteLink(tt.post, tt.target)); got != tt.want {
+				t.Errorf("viewer %q: delete link for %s targeting %s = %v, want %v\n%s", viewer, tt.post.Uri, tt.target, got, tt.want, body)
+			}
+		}
+	}
+}
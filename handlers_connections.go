@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+// fetchConnections loads one page of the accounts did follows (kind "following") or that
+// follow did (kind "followers").
+func fetchConnections(ctx context.Context, c *client.APIClient, did, kind, cursor string) (ActorsList, error) {
+	var list ActorsList
+	var next *string
+	if kind == "followers" {
+		out, err := bsky.GraphGetFollowers(ctx, c, did, cursor, 50)
+		if err != nil {
+			return list, err
+		}
+		list.Actors, next = out.Followers, out.Cursor
+	} else {
+		out, err := bsky.GraphGetFollows(ctx, c, did, cursor, 50)
+		if err != nil {
+			return list, err
+		}
+		list.Actors, next = out.Follows, out.Cursor
+	}
+	if next != nil {
+		list.Cursor = *next
+	}
+	return list, nil
+}
+
+// handleConnections renders /profile/{handle}/following and /profile/{handle}/followers.
+// It is dispatched from handleProfile.
+func handleConnections(w http.ResponseWriter, r *http.Request, c *client.APIClient, myDid, profileHandle, kind string) {
+	profileView, err := fetchProfile(r.Context(), c, profileHandle)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profileView == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	myProfile, err := fetchProfile(r.Context(), c, myDid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	people, err := fetchConnections(r.Context(), c, profileView.Did, kind, "")
+	if err != nil {
+		log.Printf("DEBUG: handleConnections - error fetching %s of %s: %v", kind, profileView.Did, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	people.ViewerDid = myDid
+
+	title := "Following"
+	if kind == "followers" {
+		title = "Followers"
+	}
+	data := ConnectionsPageData{
+		Title:    title + " - Tuiter 2006",
+		Kind:     kind,
+		Profile:  profileView,
+		Follows:  fetchFollows(r.Context(), c, profileView.Did, 50),
+		People:   people,
+		SignedIn: myProfile,
+	}
+
+	executeTemplate(w, "connections.html", data)
+}
+
+// htmxConnections returns the next page of following/followers for the "Load more" button.
+func htmxConnections(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	did := r.URL.Query().Get("did")
+	kind := r.URL.Query().Get("kind")
+	people, err := fetchConnections(r.Context(), c, did, kind, r.URL.Query().Get("cursor"))
+	if err != nil {
+		log.Printf("DEBUG: htmxConnections - error fetching %s of %s: %v", kind, did, err)
+		http.Error(w, "Failed to load people", http.StatusInternalServerError)
+		return
+	}
+	people.ViewerDid = didStr
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "actors_list", people); err != nil {
+		log.Printf("DEBUG: htmxConnections - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	data := ConnectionsPageData{Kind: kind, Profile: &bsky.ActorDefs_ProfileViewDetailed{Did: did}, People: people}
+	if err := tpl.ExecuteTemplate(w, "connections_more", data); err != nil {
+		log.Printf("DEBUG: htmxConnections - failed to execute connections_more template: %v", err)
+		fmt.Fprint(w, `<div id="connections-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// fetchFavorites loads one page of the posts did liked. The appview only serves
+// app.bsky.feed.getActorLikes for the signed-in account, so other accounts' likes are read
+// from the app.bsky.feed.like records in their repo and hydrated with fetchPostsBatch.
+func fetchFavorites(ctx context.Context, c *client.APIClient, myDid, did, cursor string) (PostsList, error) {
+	if did == myDid {
+		out, err := bsky.FeedGetActorLikes(ctx, c, did, cursor, 50)
+		if err != nil {
+			return PostsList{}, err
+		}
+		list := hydratePostsList(ctx, c, out.Feed, "", myDid)
+		if out.Cursor != nil {
+			list.Cursor = *out.Cursor
+		}
+		return list, nil
+	}
+
+	records, next, err := listLikeRecords(ctx, did, cursor)
+	if err != nil {
+		return PostsList{}, err
+	}
+	posts := map[string]*bsky.FeedDefs_PostView{}
+	const batchSize = 25
+	for i := 0; i < len(records); i += batchSize {
+		end := min(i+batchSize, len(records))
+		postsMap, err := fetchPostsBatch(ctx, c, records[i:end])
+		if err != nil {
+			log.Printf("DEBUG: fetchFavorites - fetchPostsBatch error: %v", err)
+			continue
+		}
+		for uri, pv := range postsMap {
+			posts[uri] = pv
+		}
+	}
+	// keep the like order; posts deleted since they were liked are skipped
+	items := make([]*bsky.FeedDefs_FeedViewPost, 0, len(records))
+	for _, uri := range records {
+		if pv := posts[uri]; pv != nil {
+			items = append(items, &bsky.FeedDefs_FeedViewPost{Post: pv})
+		}
+	}
+	return hydratePostsList(ctx, c, items, next, myDid), nil
+}
+
+// didDirectory looks up DID documents. It is shared so its cache of them is too.
+var didDirectory identity.Directory = identity.DefaultDirectory()
+
+// listLikeRecords lists one page of the liked post URIs in did's repo, newest first. Likes of
+// anything but posts (feed generators, for instance) are left out, as getPosts can't hydrate
+// them. Repos live on the account's own PDS, which is looked up from its DID document.
+func listLikeRecords(ctx context.Context, did, cursor string) ([]string, string, error) {
+	ident, err := didDirectory.LookupDID(ctx, syntax.DID(did))
+	if err != nil {
+		return nil, "", fmt.Errorf("resolving %s: %w", did, err)
+	}
+	pds := ident.PDSEndpoint()
+	if pds == "" {
+		return nil, "", fmt.Errorf("no PDS for %s", did)
+	}
+
+	out, err := atproto.RepoListRecords(ctx, client.NewAPIClient(pds), "app.bsky.feed.like", cursor, 50, did, false)
+	if err != nil {
+		return nil, "", fmt.Errorf("listRecords error: %w", err)
+	}
+	var uris []string
+	for _, rec := range out.Records {
+		if rec == nil || rec.Value == nil {
+			continue
+		}
+		like, ok := rec.Value.Val.(*bsky.FeedLike)
+		if !ok || like.Subject == nil {
+			continue
+		}
+		if u, err := syntax.ParseATURI(like.Subject.Uri); err == nil && u.Collection().String() == "app.bsky.feed.post" && u.RecordKey() != "" {
+			uris = append(uris, like.Subject.Uri)
+		}
+	}
+	next := ""
+	if out.Cursor != nil {
+		next = *out.Cursor
+	}
+	return uris, next, nil
+}
+
+// handleFavorites renders /profile/{handle}/favorites. It is dispatched from handleProfile.
+func handleFavorites(w http.ResponseWriter, r *http.Request, c *client.APIClient, myDid, profileHandle string) {
+	profileView, err := fetchProfile(r.Context(), c, profileHandle)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if profileView == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	myProfile, err := fetchProfile(r.Context(), c, myDid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := FavoritesPageData{
+		Title:    "Favorites - Tuiter 2006",
+		Profile:  profileView,
+		Follows:  fetchFollows(r.Context(), c, profileView.Did, 50),
+		SignedIn: myProfile,
+	}
+	posts, err := fetchFavorites(r.Context(), c, myDid, profileView.Did, "")
+	if err != nil {
+		log.Printf("DEBUG: handleFavorites - error fetching favorites of %s: %v", profileView.Did, err)
+		data.ErrorMsg = "Could not load favorites."
+	}
+	data.Posts = posts
+
+	executeTemplate(w, "favorites.html", data)
+}
+
+// htmxFavorites returns the next page of favorites for the "Load more" button.
+func htmxFavorites(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	did := r.URL.Query().Get("did")
+	posts, err := fetchFavorites(r.Context(), c, didStr, did, r.URL.Query().Get("cursor"))
+	if err != nil {
+		log.Printf("DEBUG: htmxFavorites - error fetching favorites of %s: %v", did, err)
+		http.Error(w, "Failed to load favorites", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	data := FavoritesPageData{Profile: &bsky.ActorDefs_ProfileViewDetailed{Did: did}, Posts: posts}
+	if err := tpl.ExecuteTemplate(w, "posts_list_partial.html", data); err != nil {
+		log.Printf("DEBUG: htmxFavorites - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "favorites_more", data); err != nil {
+		log.Printf("DEBUG: htmxFavorites - failed to execute favorites_more template: %v", err)
+		fmt.Fprint(w, `<div id="favorites-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+func TestListLikeRecords(t *testing.T) {
+	const liker = "did:plc:liker"
+	post := "at://did:plc:author/app.bsky.feed.post/3kpost"
+	subjects := []string{
+		post,
+		"at://did:plc:author/app.bsky.feed.generator/whats-hot",
+		"at://did:plc:author/app.bsky.feed.post",
+		"not a uri",
+		"at://did:plc:other/app.bsky.feed.post/3kother",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/com.atproto.repo.listRecords" || r.URL.Query().Get("repo") != liker {
+			http.NotFound(w, r)
+			return
+		}
+		var records []map[string]any
+		for _, uri := range subjects {
+			records = append(records, map[string]any{
+				"uri": "at://" + liker + "/app.bsky.feed.like/3k" + uri,
+				"cid": "bafyreib2rxk3rh6kzwq",
+				"value": map[string]any{
+					"$type":     "app.bsky.feed.like",
+					"subject":   map[string]any{"uri": uri, "cid": "bafyreib2rxk3rh6kzwq"},
+					"createdAt": "2025-01-01T00:00:00Z",
+				},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"records": records, "cursor": "next"})
+	}))
+	defer srv.Close()
+
+	dir := identity.NewMockDirectory()
+	dir.Insert(identity.Identity{
+		DID:      syntax.DID(liker),
+		Handle:   syntax.HandleInvalid,
+		Services: map[string]identity.ServiceEndpoint{"atproto_pds": {Type: "AtprotoPersonalDataServer", URL: srv.URL}},
+	})
+	prev := didDirectory
+	didDirectory = &dir
+	t.Cleanup(func() { didDirectory = prev })
+
+	uris, next, err := listLikeRecords(context.Background(), liker, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{post, "at://did:plc:other/app.bsky.feed.post/3kother"}; !slices.Equal(uris, want) {
+		t.Errorf("uris = %v, want only the liked posts %v", uris, want)
+	}
+	if next != "next" {
+		t.Errorf("cursor = %q, want next", next)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+// feedPostsList wraps a page of getFeed output as a PostsList with parent previews.
+func feedPostsList(ctx context.Context, c *client.APIClient, feed *bsky.FeedGetFeed_Output) PostsList {
+	list := hydratePostsList(ctx, c, feed.Feed, "", "")
+	if feed.Cursor != nil {
+		list.Cursor = *feed.Cursor
+	}
+	return list
+}
+
+// handleFeed renders /feed/{did}/{rkey}: a custom feed generator read through
+// app.bsky.feed.getFeed, with the pinned feed tabs and the feed's save/pin controls.
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	did, rkey, ok := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/feed/"), "/"), "/")
+	if !ok || did == "" || rkey == "" || strings.Contains(rkey, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	uri := feedGeneratorURI(did, rkey)
+
+	ctx, cancel := withPageDeadline(r.Context())
+	defer cancel()
+
+	var (
+		profile     *bsky.ActorDefs_ProfileViewDetailed
+		followsList []*bsky.ActorDefs_ProfileView
+		saved       []*bsky.ActorDefs_SavedFeed
+		gens        map[string]*bsky.FeedDefs_GeneratorView
+		posts       PostsList
+		feedErr     error
+	)
+	err = assemblePage(ctx, "handleFeed",
+		pageCall{Name: "profile", Run: func(ctx context.Context) (err error) {
+			profile, err = fetchProfile(ctx, c, didStr)
+			return err
+		}},
+		pageCall{Name: "follows", Optional: true, Run: func(ctx context.Context) error {
+			followsList = fetchFollows(ctx, c, didStr, 50)
+			return nil
+		}},
+		// one getFeedGenerators call describes both this feed and the pinned tabs
+		pageCall{Name: "saved feeds", Optional: true, Run: func(ctx context.Context) (err error) {
+			saved, err = loadSavedFeeds(ctx, c)
+			uris := pinnedFeedURIs(saved)
+			if !slices.Contains(uris, uri) {
+				uris = append(uris, uri)
+			}
+			gens = fetchFeedGenerators(ctx, c, uris)
+			return err
+		}},
+		pageCall{Name: "feed", Optional: true, Run: func(ctx context.Context) error {
+			// feed generators are third-party services and may be down
+			feed, err := bsky.FeedGetFeed(ctx, c, "", uri, 50)
+			if err != nil {
+				feedErr = err
+				return err
+			}
+			posts = feedPostsList(ctx, c, feed)
+			return nil
+		}},
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := FeedPageData{
+		Title:    "Feed - Tuiter 2006",
+		Profile:  profile,
+		Follows:  followsList,
+		Tabs:     feedTabs(saved, uri, gens),
+		Controls: feedControls(saved, uri),
+		Posts:    posts,
+		SignedIn: profile,
+	}
+	if g := gens[uri]; g != nil {
+		data.Generator = g
+		data.Title = g.DisplayName + " - Tuiter 2006"
+	}
+	if feedErr != nil {
+		data.ErrorMsg = "This feed is not available right now."
+	}
+	data.Posts.ViewerDid = didStr
+
+	executeTemplate(w, "feed.html", data)
+}
+
+// htmxFeed returns the next page of a custom feed for the "Load more" button.
+func htmxFeed(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.URL.Query().Get("feed")
+	feed, err := bsky.FeedGetFeed(r.Context(), c, r.URL.Query().Get("cursor"), uri, 50)
+	if err != nil {
+		log.Printf("DEBUG: htmxFeed - getFeed error for %s: %v", uri, err)
+		http.Error(w, "Failed to load feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	data := FeedPageData{Controls: FeedControlsVM{URI: uri}, Posts: feedPostsList(r.Context(), c, feed)}
+	data.Posts.ViewerDid = didStr
+	if err := tpl.ExecuteTemplate(w, "posts_list_partial.html", data); err != nil {
+		log.Printf("DEBUG: htmxFeed - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "feed_more", data); err != nil {
+		log.Printf("DEBUG: htmxFeed - failed to execute feed_more template: %v", err)
+		fmt.Fprint(w, `<div id="feed-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
+
+// handleFeeds renders the feed picker: the user's saved feeds and some suggestions to add.
+func handleFeeds(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := FeedsPageData{
+		Title:    "Feeds - Tuiter 2006",
+		Profile:  profile,
+		Follows:  fetchFollows(r.Context(), c, didStr, 50),
+		SignedIn: profile,
+	}
+
+	saved, err := loadSavedFeeds(r.Context(), c)
+	if err != nil {
+		log.Printf("DEBUG: handleFeeds - error loading saved feeds: %v", err)
+		data.ErrorMsg = "Could not load your saved feeds."
+	}
+	savedURIs := map[string]bool{}
+	var uris []string
+	for _, it := range saved {
+		if it != nil && it.Type == "feed" && feedPageURL(it.Value) != "" {
+			savedURIs[it.Value] = true
+			uris = append(uris, it.Value)
+		}
+	}
+	if len(uris) > 0 {
+		gens, err := bsky.FeedGetFeedGenerators(r.Context(), c, uris)
+		if err != nil {
+			log.Printf("DEBUG: handleFeeds - getFeedGenerators error: %v", err)
+		} else {
+			byURI := map[string]*bsky.FeedDefs_GeneratorView{}
+			for _, g := range gens.Feeds {
+				if g != nil {
+					byURI[g.Uri] = g
+				}
+			}
+			for _, uri := range uris {
+				if g := byURI[uri]; g != nil {
+					data.Saved = append(data.Saved, FeedItemVM{Generator: g, Controls: feedControls(saved, uri)})
+				}
+			}
+		}
+	}
+
+	if suggested, err := bsky.FeedGetSuggestedFeeds(r.Context(), c, "", 25); err != nil {
+		log.Printf("DEBUG: handleFeeds - getSuggestedFeeds error: %v", err)
+	} else {
+		for _, g := range suggested.Feeds {
+			if g != nil && !savedURIs[g.Uri] {
+				data.Suggested = append(data.Suggested, FeedItemVM{Generator: g, Controls: feedControls(saved, g.Uri)})
+			}
+		}
+	}
+
+	executeTemplate(w, "feeds.html", data)
+}
+
+// handleSaveFeed handles POST /feeds/save?feed=<at-uri>&action=save|remove|pin|unpin and
+// returns the updated feed controls.
+func handleSaveFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, _, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.FormValue("feed")
+	if feedPageURL(uri) == "" {
+		http.Error(w, "Invalid feed", http.StatusBadRequest)
+		return
+	}
+	saved, err := updateSavedFeeds(r.Context(), c, uri, r.FormValue("action"))
+	if err != nil {
+		log.Printf("DEBUG: handleSaveFeed - error updating saved feeds for %s: %v", uri, err)
+		http.Error(w, "Failed to update feeds: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "feed_controls", feedControls(saved, uri)); err != nil {
+		log.Printf("DEBUG: handleSaveFeed - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
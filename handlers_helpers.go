@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
-	"time"
+	"sync"
 
 	bsky "github.com/bluesky-social/indigo/api/bsky"
 	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 )
 
 // buildPostURIFromRequest extracts the post URI either from query param `uri` or
@@ -33,7 +35,7 @@ func buildPostURIFromRequest(ctx context.Context, r *http.Request, c *client.API
 
 // fetchThreadAndExtract fetches a post thread and returns the main post, any replies, and the thread root node.
 func fetchThreadAndExtract(ctx context.Context, c *client.APIClient, postURI string) (*bsky.FeedDefs_PostView, []*bsky.FeedDefs_PostView, *bsky.FeedDefs_ThreadViewPost, error) {
-	thread, err := bsky.FeedGetPostThread(ctx, c, 100, 0, postURI)
+	thread, err := bsky.FeedGetPostThread(ctx, c, 100, threadParentHeight, postURI)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -65,23 +67,6 @@ func fetchThreadAndExtract(ctx context.Context, c *client.APIClient, postURI str
 	return main, replies, root, nil
 }
 
-// fetchAuthorDetails resolves an author handle to DID, fetches profile and follows.
-func fetchAuthorDetails(ctx context.Context, c *client.APIClient, authorHandle string) (*bsky.ActorDefs_ProfileViewDetailed, []*bsky.ActorDefs_ProfileView, error) {
-	if authorHandle == "" {
-		return nil, nil, fmt.Errorf("empty author handle")
-	}
-	did, err := resolveHandleToDID(ctx, c, authorHandle)
-	if err != nil {
-		return nil, nil, err
-	}
-	p, err := bsky.ActorGetProfile(ctx, c, did)
-	if err != nil {
-		return nil, nil, err
-	}
-	follows := fetchFollows(ctx, c, did, 50)
-	return p, follows, nil
-}
-
 // extractReplyParentURI returns the parent URI if the post record contains a reply ref.
 func extractReplyParentURI(pv *bsky.FeedDefs_PostView) string {
 	if pv == nil || pv.Record == nil || pv.Record.Val == nil {
@@ -98,98 +83,282 @@ func extractReplyParentURI(pv *bsky.FeedDefs_PostView) string {
 	return ""
 }
 
-// fetchPostsBatch fetches posts for the provided URIs and returns a map uri->postView
+// fetchPostsBatch fetches posts for the provided URIs and returns a map uri->postView.
+// Posts the viewer looked at recently are served from postCache.
 func fetchPostsBatch(ctx context.Context, c *client.APIClient, uris []string) (map[string]*bsky.FeedDefs_PostView, error) {
 	if len(uris) == 0 {
 		return nil, nil
 	}
-	resp, err := bsky.FeedGetPosts(ctx, c, uris)
-	if err != nil {
-		return nil, fmt.Errorf("FeedGetPosts error: %w", err)
+	return cachedPostsBatch(ctx, c, uris, func(ctx context.Context, uris []string) (map[string]*bsky.FeedDefs_PostView, error) {
+		resp, err := bsky.FeedGetPosts(ctx, c, uris)
+		if err != nil {
+			return nil, fmt.Errorf("FeedGetPosts error: %w", err)
+		}
+		m := make(map[string]*bsky.FeedDefs_PostView)
+		for _, p := range resp.Posts {
+			m[p.Uri] = p
+		}
+		return m, nil
+	})
+}
+
+// hydratePostsList wraps a page of feed items (timeline, author feed, custom feed, search
+// results...) as a PostsList with the parent and root previews its replies reference.
+func hydratePostsList(ctx context.Context, c *client.APIClient, items []*bsky.FeedDefs_FeedViewPost, cursor, viewerDid string) PostsList {
+	return PostsList{Items: items, Cursor: cursor, ParentPreviews: fetchParentPreviews(ctx, c, items), ViewerDid: viewerDid}
+}
+
+// parentPreviewBatchSize is the getPosts limit; parentPreviewWorkers bounds how many batches
+// are in flight at once.
+const (
+	parentPreviewBatchSize = 25
+	parentPreviewWorkers   = 4
+)
+
+// fetchParentPreviews batch-fetches the reply parents and roots referenced by feed items and
+// returns their previews keyed by URI, ready for PostsList.ParentPreviews. Batches are fetched
+// concurrently; failed batches are logged and skipped, and no new batches start once ctx is done.
+func fetchParentPreviews(ctx context.Context, c *client.APIClient, items []*bsky.FeedDefs_FeedViewPost) map[string]ParentInfo {
+	seen := map[string]struct{}{}
+	var uris []string
+	for _, fv := range items {
+		if fv == nil || fv.Post == nil {
+			continue
+		}
+		candidates := []string{extractReplyParentURI(fv.Post)}
+		for _, pi := range GetReplyChainInfos(fv.Post) {
+			candidates = append(candidates, pi.Uri)
+		}
+		for _, u := range candidates {
+			if _, ok := seen[u]; u == "" || ok {
+				continue
+			}
+			seen[u] = struct{}{}
+			uris = append(uris, u)
+		}
 	}
-	m := make(map[string]*bsky.FeedDefs_PostView)
-	for _, p := range resp.Posts {
-		m[p.Uri] = p
+
+	var batches [][]string
+	for i := 0; i < len(uris); i += parentPreviewBatchSize {
+		batches = append(batches, uris[i:min(i+parentPreviewBatchSize, len(uris))])
 	}
-	return m, nil
-}
 
-// buildParentChain walks from an immediate parent up to the reply root (or stops at maxDepth)
-// Returns chain ordered from root ... parent (chronological ancestor order)
-func buildParentChain(ctx context.Context, c *client.APIClient, startURI string, maxDepth int) ([]*bsky.FeedDefs_PostView, error) {
-	var chain []*bsky.FeedDefs_PostView
-	currentURI := startURI
-	seen := map[string]bool{}
-	for depth := 0; depth < maxDepth && currentURI != ""; depth++ {
-		if seen[currentURI] {
-			break
+	previews := map[string]ParentInfo{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parentPreviewWorkers)
+	for _, batch := range batches {
+		// check first: when a worker frees up as ctx ends, select may pick either case
+		if ctx.Err() == nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			log.Printf("DEBUG: fetchParentPreviews - context done: %v", ctx.Err())
+			wg.Wait()
+			return previews
 		}
-		seen[currentURI] = true
+		wg.Add(1)
+		go func(batch []string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			postsMap, err := fetchPostsBatch(ctx, c, batch)
+			if err != nil {
+				log.Printf("DEBUG: fetchParentPreviews - fetchPostsBatch error: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for uri, pv := range postsMap {
+				if pv != nil {
+					previews[uri] = parentInfoFromPost(pv)
+				}
+			}
+		}(batch)
+	}
+	wg.Wait()
+	return previews
+}
 
-		// fetch current
-		postsMap, err := fetchPostsBatch(ctx, c, []string{currentURI})
-		if err != nil {
-			return chain, err
+// parentInfoFromPost builds the preview shown above a reply for its parent or root post.
+func parentInfoFromPost(pv *bsky.FeedDefs_PostView) ParentInfo {
+	pi := ParentInfo{Uri: pv.Uri}
+	if pv.Author != nil {
+		if pv.Author.DisplayName != nil && *pv.Author.DisplayName != "" {
+			pi.AuthorName = *pv.Author.DisplayName
+		} else {
+			pi.AuthorName = pv.Author.Handle
 		}
-		p, ok := postsMap[currentURI]
-		if !ok || p == nil {
-			// not found, stop walking
-			break
+		pi.AuthorHandle = pv.Author.Handle
+		if pv.Author.Avatar != nil {
+			pi.Avatar = *pv.Author.Avatar
 		}
-		// prepend to chain later; collect in reverse order first
-		chain = append([]*bsky.FeedDefs_PostView{p}, chain...)
+	}
+	pi.Text = getPostText(pv.Record)
+	if pv.Uri != "" {
+		pi.PostURL = getPostURL(pv)
+	}
+	pi.IndexedAt = pv.IndexedAt
+	pi.Media = GetPostMedia(pv)
+	pi.LikeCount = getLikeCount(pv)
+	pi.ReplyCount = getReplyCount(pv)
+	pi.RepostCount = getRepostCount(pv)
+	pi.IsFav = getIsFav(pv)
+	pi.IsRt = getIsRt(pv)
+	return pi
+}
 
-		// determine next parent
-		next := extractReplyParentURI(p)
-		if next == "" || next == currentURI {
-			break
+// fetchPost fetches a single post view by URI.
+func fetchPost(ctx context.Context, c *client.APIClient, uri string) (*bsky.FeedDefs_PostView, error) {
+	postsMap, err := fetchPostsBatch(ctx, c, []string{uri})
+	if err != nil {
+		return nil, err
+	}
+	pv, ok := postsMap[uri]
+	if !ok || pv == nil {
+		return nil, fmt.Errorf("post not found: %s", uri)
+	}
+	return pv, nil
+}
+
+// recordKeyFromURI extracts the record key from an at:// record URI.
+func recordKeyFromURI(uri string) (string, error) {
+	aturi, err := syntax.ParseATURI(uri)
+	if err != nil {
+		return "", err
+	}
+	rkey := aturi.RecordKey().String()
+	if rkey == "" {
+		return "", fmt.Errorf("no record key in uri: %s", uri)
+	}
+	return rkey, nil
+}
+
+// threadParentHeight is how many ancestors getPostThread is asked to include, and
+// maxParentChain caps how many are shown above a post.
+const (
+	threadParentHeight = 80
+	maxParentChain     = 200
+)
+
+// buildParentChain collects node's ancestors from the getPostThread Parent union, ordered
+// root ... parent. A deleted or blocked ancestor ends the walk with a placeholder, as nothing
+// is known about the posts above it; posts by muted accounts are kept as placeholders too.
+// When the top of the returned ancestry is itself a reply (the parentHeight limit was hit), the
+// rest of the ancestors up to maxDepth are fetched from there with a single getPostThread call.
+// Ancestor URIs are only known one level at a time, so getPosts can't batch this walk.
+func buildParentChain(ctx context.Context, c *client.APIClient, node *bsky.FeedDefs_ThreadViewPost, maxDepth int) ([]ThreadItem, error) {
+	var chain []ThreadItem // parent first, reversed at the end
+	seen := map[string]bool{}
+	for node != nil && node.Post != nil && len(chain) < maxDepth {
+		parent := node.Parent
+		if parent == nil {
+			parentURI := extractReplyParentURI(node.Post)
+			if parentURI == "" {
+				break // reached the root
+			}
+			// parentURI is one of the remaining posts; ask for all the others above it
+			remaining := maxDepth - len(chain)
+			thread, err := bsky.FeedGetPostThread(ctx, c, 0, int64(remaining-1), parentURI)
+			if err != nil {
+				slices.Reverse(chain)
+				return chain, err
+			}
+			if thread.Thread == nil {
+				break
+			}
+			parent = &bsky.FeedDefs_ThreadViewPost_Parent{
+				FeedDefs_ThreadViewPost: thread.Thread.FeedDefs_ThreadViewPost,
+				FeedDefs_NotFoundPost:   thread.Thread.FeedDefs_NotFoundPost,
+				FeedDefs_BlockedPost:    thread.Thread.FeedDefs_BlockedPost,
+			}
+		}
+		switch {
+		case parent.FeedDefs_NotFoundPost != nil:
+			log.Printf("DEBUG: buildParentChain - ancestor not found: %s", parent.FeedDefs_NotFoundPost.Uri)
+			chain = append(chain, ThreadItem{Unavailable: &UnavailablePost{Kind: unavailableDeleted, Uri: parent.FeedDefs_NotFoundPost.Uri}})
+			node = nil
+		case parent.FeedDefs_BlockedPost != nil:
+			log.Printf("DEBUG: buildParentChain - ancestor blocked: %s", parent.FeedDefs_BlockedPost.Uri)
+			chain = append(chain, ThreadItem{Unavailable: &UnavailablePost{Kind: unavailableBlocked, Uri: parent.FeedDefs_BlockedPost.Uri}})
+			node = nil
+		case parent.FeedDefs_ThreadViewPost != nil && parent.FeedDefs_ThreadViewPost.Post != nil:
+			node = parent.FeedDefs_ThreadViewPost
+			if seen[node.Post.Uri] {
+				node = nil
+				break
+			}
+			seen[node.Post.Uri] = true
+			chain = append(chain, threadItemFromPost(node.Post))
+		default:
+			node = nil
 		}
-		currentURI = next
-		// small sleep to avoid hammering remote servers in pathological loops
-		time.Sleep(20 * time.Millisecond)
 	}
+	slices.Reverse(chain)
 	return chain, nil
 }
 
 // preparePostPageData performs the steps required to assemble PostPageData for templates.
 func preparePostPageData(ctx context.Context, r *http.Request, c *client.APIClient, myDid string) (PostPageData, error) {
+	ctx, cancel := withPageDeadline(ctx)
+	defer cancel()
+
 	postURI, err := buildPostURIFromRequest(ctx, r, c)
 	if err != nil {
 		return PostPageData{}, err
 	}
-	mainPost, replies, threadRoot, err := fetchThreadAndExtract(ctx, c, postURI)
-	if err != nil {
-		return PostPageData{}, err
-	}
-	profile, err := fetchProfile(ctx, c, myDid)
+
+	var (
+		mainPost   *bsky.FeedDefs_PostView
+		replies    []*bsky.FeedDefs_PostView
+		threadRoot *bsky.FeedDefs_ThreadViewPost
+		profile    *bsky.ActorDefs_ProfileViewDetailed
+	)
+	err = assemblePage(ctx, "preparePostPageData",
+		pageCall{Name: "thread", Run: func(ctx context.Context) (err error) {
+			mainPost, replies, threadRoot, err = fetchThreadAndExtract(ctx, c, postURI)
+			return err
+		}},
+		pageCall{Name: "signed-in profile", Run: func(ctx context.Context) (err error) {
+			profile, err = fetchProfile(ctx, c, myDid)
+			return err
+		}},
+	)
 	if err != nil {
 		return PostPageData{}, err
 	}
 
-	var parentChain []*bsky.FeedDefs_PostView
+	// the parent chain and author sidebar both hang off the main post, so they run once it's known
+	var (
+		parentChain       []ThreadItem
+		postAuthor        *bsky.ActorDefs_ProfileViewDetailed
+		postAuthorFollows []*bsky.ActorDefs_ProfileView
+	)
+	var calls []pageCall
 	// if mainPost itself is a reply, walk up to root
-	if mainPost != nil {
-		parentURI := extractReplyParentURI(mainPost)
-		if parentURI != "" {
-			chain, err := buildParentChain(ctx, c, parentURI, 20)
-			if err != nil {
-				// log and continue with what we have
-				fmt.Printf("DEBUG: preparePostPageData - error building parent chain: %v\n", err)
-			} else {
-				parentChain = chain
-			}
-		}
+	if extractReplyParentURI(mainPost) != "" {
+		calls = append(calls, pageCall{Name: "parent chain", Optional: true, Run: func(ctx context.Context) (err error) {
+			parentChain, err = buildParentChain(ctx, c, threadRoot, maxParentChain)
+			return err
+		}})
 	}
-
-	var postAuthor *bsky.ActorDefs_ProfileViewDetailed
-	var postAuthorFollows []*bsky.ActorDefs_ProfileView
 	if mainPost != nil && mainPost.Author != nil {
-		p, follows, err := fetchAuthorDetails(ctx, c, mainPost.Author.Handle)
-		if err == nil {
-			postAuthor = p
-			postAuthorFollows = follows
-		}
+		authorDid := mainPost.Author.Did
+		calls = append(calls,
+			pageCall{Name: "author profile", Optional: true, Run: func(ctx context.Context) (err error) {
+				postAuthor, err = fetchProfile(ctx, c, authorDid)
+				return err
+			}},
+			pageCall{Name: "author follows", Optional: true, Run: func(ctx context.Context) error {
+				postAuthorFollows = fetchFollows(ctx, c, authorDid, 50)
+				return nil
+			}},
+		)
 	}
+	// optional calls only log their failures
+	_ = assemblePage(ctx, "preparePostPageData", calls...)
 
 	// Debug logging: counts and sample URIs
 	if replies != nil {
@@ -247,7 +416,7 @@ func prepareProfilePageData(ctx context.Context, c *client.APIClient, myDid stri
 		Profile:       profileView,
 		Feed:          authorFeed,
 		Follows:       followsList,
-		Posts:         PostsList{Items: authorFeed.Feed, Cursor: getCursorFromAuthorFeed(authorFeed)},
+		Posts:         hydratePostsList(ctx, c, authorFeed.Feed, getCursorFromAuthorFeed(authorFeed), myDid),
 		PostBoxHandle: postBoxHandle,
 		// SignedIn is the currently authenticated profile
 		SignedIn: myProfile,
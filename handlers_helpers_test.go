@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+// fakeGetPosts is an httptest server answering app.bsky.feed.getPosts with a post per
+// requested URI. Posts carry a like count but no reply or repost counts. Each request calls
+// hold with its URIs before answering, so tests can delay or block batches.
+type fakeGetPosts struct {
+	*httptest.Server
+	hold     func(r *http.Request, uris []string)
+	requests atomic.Int64
+	inFlight atomic.Int64
+	peak     atomic.Int64
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func newFakeGetPosts(t *testing.T, hold func(r *http.Request, uris []string)) *fakeGetPosts {
+	f := &fakeGetPosts{hold: hold}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGetPosts) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/xrpc/app.bsky.feed.getPosts" {
+		http.NotFound(w, r)
+		return
+	}
+	f.requests.Add(1)
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		p := f.peak.Load()
+		if n <= p || f.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+
+	uris := r.URL.Query()["uris"]
+	f.mu.Lock()
+	f.batches = append(f.batches, uris)
+	f.mu.Unlock()
+	if f.hold != nil {
+		f.hold(r, uris)
+	}
+
+	posts := make([]map[string]any, 0, len(uris))
+	for _, uri := range uris {
+		posts = append(posts, map[string]any{
+			"uri":       uri,
+			"cid":       "bafyreib2rxk3rh6kzwq",
+			"author":    map[string]any{"did": "did:plc:parent", "handle": "parent.test"},
+			"record":    map[string]any{"$type": "app.bsky.feed.post", "text": "text of " + uri, "createdAt": "2025-01-01T00:00:00Z"},
+			"indexedAt": "2025-01-01T00:00:00Z",
+			"likeCount": 2,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"posts": posts})
+}
+
+// replyItems returns n feed items replying to n distinct parents. URIs are unique per call,
+// since fetched posts stay in postCache.
+func replyItems(prefix string, n int) ([]*bsky.FeedDefs_FeedViewPost, []string) {
+	prefix = fmt.Sprintf("%s%d-", prefix, time.Now().UnixNano())
+	items := make([]*bsky.FeedDefs_FeedViewPost, n)
+	parents := make([]string, n)
+	for i := range n {
+		parents[i] = fmt.Sprintf("at://did:plc:parent/app.bsky.feed.post/%s%03d", prefix, i)
+		ref := &atproto.RepoStrongRef{Uri: parents[i], Cid: "bafyreib2rxk3rh6kzwq"}
+		items[i] = &bsky.FeedDefs_FeedViewPost{Post: &bsky.FeedDefs_PostView{
+			Uri:    fmt.Sprintf("at://did:plc:child/app.bsky.feed.post/%s%03d", prefix, i),
+			Author: &bsky.ActorDefs_ProfileViewBasic{Did: "did:plc:child", Handle: "child.test"},
+			Record: &util.LexiconTypeDecoder{Val: &bsky.FeedPost{
+				Text:  "reply",
+				Reply: &bsky.FeedPost_ReplyRef{Parent: ref, Root: ref},
+			}},
+		}}
+	}
+	return items, parents
+}
+
+func TestFetchParentPreviewsBatches(t *testing.T) {
+	srv := newFakeGetPosts(t, nil)
+	items, parents := replyItems("batch", 60)
+	// a second reply to the first parent must not fetch it twice
+	items = append(items, items[0])
+
+	previews := fetchParentPreviews(context.Background(), client.NewAPIClient(srv.URL), items)
+
+	if len(srv.batches) != 3 {
+		t.Fatalf("got %d getPosts calls, want 3", len(srv.batches))
+	}
+	seen := map[string]bool{}
+	for _, batch := range srv.batches {
+		if len(batch) > parentPreviewBatchSize {
+			t.Errorf("batch of %d URIs, limit is %d", len(batch), parentPreviewBatchSize)
+		}
+		for _, uri := range batch {
+			if seen[uri] {
+				t.Errorf("%s fetched twice", uri)
+			}
+			seen[uri] = true
+		}
+	}
+	if len(previews) != len(parents) {
+		t.Fatalf("got %d previews, want %d", len(previews), len(parents))
+	}
+	for _, uri := range parents {
+		pi, ok := previews[uri]
+		if !ok {
+			t.Fatalf("no preview for %s", uri)
+		}
+		// the fake omits replyCount and repostCount
+		if pi.ReplyCount != 0 || pi.RepostCount != 0 || pi.LikeCount != 2 {
+			t.Errorf("%s counts = %d/%d/%d, want 0/0/2", uri, pi.ReplyCount, pi.RepostCount, pi.LikeCount)
+		}
+		if pi.Text != "text of "+uri || pi.AuthorHandle != "parent.test" {
+			t.Errorf("%s preview = %+v", uri, pi)
+		}
+	}
+}
+
+func TestFetchParentPreviewsBoundedWorkers(t *testing.T) {
+	srv := newFakeGetPosts(t, func(*http.Request, []string) { time.Sleep(30 * time.Millisecond) })
+	items, parents := replyItems("workers", 10*parentPreviewBatchSize)
+
+	previews := fetchParentPreviews(context.Background(), client.NewAPIClient(srv.URL), items)
+
+	if len(previews) != len(parents) {
+		t.Errorf("got %d previews, want %d", len(previews), len(parents))
+	}
+	if got := srv.requests.Load(); got != 10 {
+		t.Errorf("got %d getPosts calls, want 10", got)
+	}
+	if peak := srv.peak.Load(); peak > parentPreviewWorkers || peak < 2 {
+		t.Errorf("peak concurrent getPosts calls = %d, want between 2 and %d", peak, parentPreviewWorkers)
+	}
+}
+
+func TestFetchParentPreviewsCancelled(t *testing.T) {
+	items, parents := replyItems("cancel", 10*parentPreviewBatchSize)
+	release := make(chan struct{})
+	blocked := make(chan struct{}, 10)
+	// the batch holding the first parent answers right away, every other batch hangs until
+	// its request is cancelled
+	srv := newFakeGetPosts(t, func(r *http.Request, uris []string) {
+		if uris[0] == parents[0] {
+			return
+		}
+		blocked <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for range parentPreviewWorkers {
+			<-blocked
+		}
+		cancel()
+	}()
+
+	done := make(chan map[string]ParentInfo)
+	go func() { done <- fetchParentPreviews(ctx, client.NewAPIClient(srv.URL), items) }()
+	var previews map[string]ParentInfo
+	select {
+	case previews = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetchParentPreviews did not return after the context was cancelled")
+	}
+
+	requests := srv.requests.Load()
+	if requests > 1+parentPreviewWorkers {
+		t.Errorf("%d getPosts calls were made, want at most %d", requests, 1+parentPreviewWorkers)
+	}
+	for _, uri := range parents[:parentPreviewBatchSize] {
+		if _, ok := previews[uri]; !ok {
+			t.Errorf("missing preview %s from the batch that finished before the cancel", uri)
+		}
+	}
+	if len(previews) != parentPreviewBatchSize {
+		t.Errorf("got %d previews, want only the %d of the finished batch", len(previews), parentPreviewBatchSize)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := srv.requests.Load(); got != requests {
+		t.Errorf("%d getPosts calls started after fetchParentPreviews returned", got-requests)
+	}
+}
+
+// fakeThread serves app.bsky.feed.getPostThread for a straight reply chain: post i replies to
+// post i-1 and post 0 is the root. Posts listed in deleted come back as notFoundPost.
+type fakeThread struct {
+	*httptest.Server
+	uris    []string
+	deleted map[int]bool
+	heights []int
+}
+
+func newFakeThread(t *testing.T, n int) *fakeThread {
+	f := &fakeThread{deleted: map[int]bool{}}
+	for i := range n {
+		f.uris = append(f.uris, fmt.Sprintf("at://did:plc:author/app.bsky.feed.post/%04d", i))
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeThread) node(i, height int) map[string]any {
+	if f.deleted[i] {
+		return map[string]any{"$type": "app.bsky.feed.defs#notFoundPost", "uri": f.uris[i], "notFound": true}
+	}
+	record := map[string]any{"$type": "app.bsky.feed.post", "text": "post", "createdAt": "2025-01-01T00:00:00Z"}
+	if i > 0 {
+		record["reply"] = map[string]any{
+			"root":   map[string]any{"uri": f.uris[0], "cid": "bafyreib2rxk3rh6kzwq"},
+			"parent": map[string]any{"uri": f.uris[i-1], "cid": "bafyreib2rxk3rh6kzwq"},
+		}
+	}
+	n := map[string]any{
+		"$type": "app.bsky.feed.defs#threadViewPost",
+		"post": map[string]any{
+			"uri":       f.uris[i],
+			"cid":       "bafyreib2rxk3rh6kzwq",
+			"author":    map[string]any{"did": "did:plc:author", "handle": "author.test"},
+			"record":    record,
+			"indexedAt": "2025-01-01T00:00:00Z",
+		},
+	}
+	if i > 0 && height > 0 {
+		n["parent"] = f.node(i-1, height-1)
+	}
+	return n
+}
+
+func (f *fakeThread) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/xrpc/app.bsky.feed.getPostThread" {
+		http.NotFound(w, r)
+		return
+	}
+	height, _ := strconv.Atoi(r.URL.Query().Get("parentHeight"))
+	f.heights = append(f.heights, height)
+	i := slices.Index(f.uris, r.URL.Query().Get("uri"))
+	if i < 0 {
+		http.Error(w, `{"error":"NotFound"}`, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"thread": f.node(i, height)})
+}
+
+func TestBuildParentChain(t *testing.T) {
+	tests := []struct {
+		name        string
+		posts       int
+		deleted     int // index of a deleted ancestor, 0 for none
+		wantLen     int
+		wantHeights []int // parentHeight of each getPostThread call after the page's own
+	}{
+		{name: "within parentHeight", posts: 50, wantLen: 49},
+		{name: "past parentHeight", posts: 150, wantLen: 149, wantHeights: []int{maxParentChain - threadParentHeight - 1}},
+		{name: "capped", posts: 500, wantLen: maxParentChain, wantHeights: []int{maxParentChain - threadParentHeight - 1}},
+		{name: "deleted ancestor", posts: 150, deleted: 30, wantLen: 119, wantHeights: []int{maxParentChain - threadParentHeight - 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeThread(t, tt.posts)
+			if tt.deleted > 0 {
+				srv.deleted[tt.deleted] = true
+			}
+			ctx := context.Background()
+			c := client.NewAPIClient(srv.URL)
+			leaf := srv.uris[len(srv.uris)-1]
+			thread, err := bsky.FeedGetPostThread(ctx, c, 0, threadParentHeight, leaf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.heights = nil
+
+			chain, err := buildParentChain(ctx, c, thread.Thread.FeedDefs_ThreadViewPost, maxParentChain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(srv.heights, tt.wantHeights) {
+				t.Errorf("getPostThread parentHeights = %v, want %v", srv.heights, tt.wantHeights)
+			}
+			if len(chain) != tt.wantLen {
+				t.Fatalf("chain has %d posts, want %d", len(chain), tt.wantLen)
+			}
+			// ordered root ... parent, ending right above the leaf
+			first := len(srv.uris) - 1 - tt.wantLen
+			for i, item := range chain {
+				want := srv.uris[first+i]
+				switch {
+				case i == 0 && tt.deleted > 0:
+					if item.Unavailable == nil || item.Unavailable.Kind != unavailableDeleted || item.Unavailable.Uri != want {
+						t.Errorf("chain[0] = %+v, want a deleted placeholder for %s", item.Unavailable, want)
+					}
+				case item.Post == nil || item.Post.Uri != want:
+					t.Fatalf("chain[%d] = %+v, want %s", i, item, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+// handleLists renders /lists: the signed-in user's curate lists and a form to create one.
+func handleLists(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := ListsPageData{
+		Title:    "Lists - Tuiter 2006",
+		ErrorMsg: r.URL.Query().Get("error"),
+		Profile:  profile,
+		Follows:  fetchFollows(r.Context(), c, didStr, 50),
+		SignedIn: profile,
+	}
+	lists, err := fetchCurateLists(r.Context(), c, didStr)
+	if err != nil {
+		log.Printf("DEBUG: handleLists - getLists error: %v", err)
+		data.ErrorMsg = "Could not load your lists."
+	}
+	data.Lists = lists
+
+	executeTemplate(w, "lists.html", data)
+}
+
+// handleCreateList handles the /lists form: it creates a curate list and opens it.
+func handleCreateList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	description := strings.TrimSpace(r.FormValue("description"))
+	switch {
+	case name == "":
+		http.Redirect(w, r, "/lists?error="+url.QueryEscape("Your list needs a name."), http.StatusFound)
+		return
+	case utf8.RuneCountInString(name) > maxListNameLength:
+		http.Redirect(w, r, "/lists?error="+url.QueryEscape(fmt.Sprintf("List names are limited to %d characters.", maxListNameLength)), http.StatusFound)
+		return
+	case utf8.RuneCountInString(description) > maxListDescriptionLength:
+		http.Redirect(w, r, "/lists?error="+url.QueryEscape(fmt.Sprintf("Descriptions are limited to %d characters.", maxListDescriptionLength)), http.StatusFound)
+		return
+	}
+
+	purpose := curateListPurpose
+	list := &bsky.GraphList{
+		CreatedAt: syntax.DatetimeNow().String(),
+		Name:      name,
+		Purpose:   &purpose,
+	}
+	if description != "" {
+		list.Description = &description
+	}
+	out, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
+		Collection: listCollection,
+		Repo:       didStr,
+		Record:     &util.LexiconTypeDecoder{Val: list},
+	})
+	if err != nil {
+		log.Printf("DEBUG: handleCreateList - error creating list: %v", err)
+		http.Error(w, "Failed to create list: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, listPageURL(out.Uri), http.StatusFound)
+}
+
+// handleDeleteList handles POST /lists/delete?list=<at-uri>: it deletes one of the signed-in
+// user's lists along with its listitem records, then goes back to /lists.
+func handleDeleteList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.FormValue("list")
+	aturi, err := syntax.ParseATURI(uri)
+	if err != nil || aturi.RecordKey() == "" {
+		http.Error(w, "invalid list", http.StatusBadRequest)
+		return
+	}
+	if aturi.Authority().String() != didStr || aturi.Collection().String() != listCollection {
+		http.Error(w, "You can only delete your own lists", http.StatusForbidden)
+		return
+	}
+
+	itemURIs, err := listItemURIs(r.Context(), c, uri)
+	if err != nil {
+		log.Printf("DEBUG: handleDeleteList - error listing items of %s: %v", uri, err)
+		http.Error(w, "Failed to delete list: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := deleteList(r.Context(), c, didStr, aturi.RecordKey().String(), itemURIs); err != nil {
+		log.Printf("DEBUG: handleDeleteList - error deleting list %s: %v", uri, err)
+		http.Error(w, "Failed to delete list: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/lists")
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleList renders /list/{did}/{rkey}: the list's timeline, or its members with
+// ?view=members.
+func handleList(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	did, rkey, ok := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/list/"), "/"), "/")
+	if !ok || did == "" || rkey == "" || strings.Contains(rkey, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	uri := listURI(did, rkey)
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	view := "updates"
+	if r.URL.Query().Get("view") == "members" {
+		view = "members"
+	}
+	data := ListPageData{
+		Title:    "List - Tuiter 2006",
+		View:     view,
+		Profile:  profile,
+		Follows:  fetchFollows(r.Context(), c, didStr, 50),
+		SignedIn: profile,
+	}
+
+	members, err := bsky.GraphGetList(r.Context(), c, "", 50, uri)
+	if err == nil && members.List == nil {
+		err = fmt.Errorf("getList returned no list")
+	}
+	if err != nil {
+		log.Printf("DEBUG: handleList - getList error for %s: %v", uri, err)
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+	data.List = members.List
+	data.Title = members.List.Name + " - Tuiter 2006"
+	data.Owner = members.List.Creator != nil && members.List.Creator.Did == didStr
+
+	if view == "members" {
+		data.Members = ListMembersVM{ListURI: uri, Items: members.Items, Owner: data.Owner, ViewerDid: didStr}
+		if members.Cursor != nil {
+			data.Members.Cursor = *members.Cursor
+		}
+	} else {
+		feed, err := bsky.FeedGetListFeed(r.Context(), c, "", 50, uri)
+		if err != nil {
+			log.Printf("DEBUG: handleList - getListFeed error for %s: %v", uri, err)
+			data.ErrorMsg = "Could not load this list's updates."
+		} else {
+			data.Posts = hydratePostsList(r.Context(), c, feed.Feed, "", didStr)
+			if feed.Cursor != nil {
+				data.Posts.Cursor = *feed.Cursor
+			}
+		}
+	}
+
+	executeTemplate(w, "list.html", data)
+}
+
+// htmxListFeed returns the next page of a list's timeline for the "Load more" button.
+func htmxListFeed(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.URL.Query().Get("list")
+	feed, err := bsky.FeedGetListFeed(r.Context(), c, r.URL.Query().Get("cursor"), 50, uri)
+	if err != nil {
+		log.Printf("DEBUG: htmxListFeed - getListFeed error for %s: %v", uri, err)
+		http.Error(w, "Failed to load updates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	data := ListPageData{
+		List:  &bsky.GraphDefs_ListView{Uri: uri},
+		Posts: hydratePostsList(r.Context(), c, feed.Feed, "", didStr),
+	}
+	if feed.Cursor != nil {
+		data.Posts.Cursor = *feed.Cursor
+	}
+	if err := tpl.ExecuteTemplate(w, "posts_list_partial.html", data); err != nil {
+		log.Printf("DEBUG: htmxListFeed - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "list_more", data); err != nil {
+		log.Printf("DEBUG: htmxListFeed - failed to execute list_more template: %v", err)
+		fmt.Fprint(w, `<div id="list-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
+
+// htmxListMembers returns the next page of a list's members for the "Load more" button.
+func htmxListMembers(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.URL.Query().Get("list")
+	out, err := bsky.GraphGetList(r.Context(), c, r.URL.Query().Get("cursor"), 50, uri)
+	if err != nil {
+		log.Printf("DEBUG: htmxListMembers - getList error for %s: %v", uri, err)
+		http.Error(w, "Failed to load members", http.StatusInternalServerError)
+		return
+	}
+
+	members := ListMembersVM{
+		ListURI:   uri,
+		Items:     out.Items,
+		Owner:     out.List != nil && out.List.Creator != nil && out.List.Creator.Did == didStr,
+		ViewerDid: didStr,
+	}
+	if out.Cursor != nil {
+		members.Cursor = *out.Cursor
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "list_members", members); err != nil {
+		log.Printf("DEBUG: htmxListMembers - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "list_members_more", members); err != nil {
+		log.Printf("DEBUG: htmxListMembers - failed to execute list_members_more template: %v", err)
+		fmt.Fprint(w, `<div id="list-members-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
+
+// htmxListMemberships renders the "lists" picker of a profile: every curate list of the
+// signed-in user with an add or remove button for the profile.
+func htmxListMemberships(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	subject := r.URL.Query().Get("did")
+	lists, err := fetchCurateLists(r.Context(), c, didStr)
+	if err != nil {
+		log.Printf("DEBUG: htmxListMemberships - getLists error: %v", err)
+		http.Error(w, "Failed to load lists", http.StatusInternalServerError)
+		return
+	}
+	items, err := listItemRecords(r.Context(), c, didStr, subject)
+	if err != nil {
+		log.Printf("DEBUG: htmxListMemberships - error listing items for %s: %v", subject, err)
+		http.Error(w, "Failed to load lists", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "list_memberships", listMemberships(lists, items, subject)); err != nil {
+		log.Printf("DEBUG: htmxListMemberships - Template error: %v", err)
+	}
+}
+
+// handleListMember handles POST /lists/member?list=&did=&action=add|remove(&item=): it adds
+// a profile to one of the signed-in user's lists, or removes the listitem record. It returns
+// the updated picker row, or nothing for removals from the members page (from=members).
+func handleListMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	list := r.FormValue("list")
+	subject := r.FormValue("did")
+	aturi, err := syntax.ParseATURI(list)
+	if err != nil || aturi.Authority().String() != didStr || aturi.Collection().String() != listCollection {
+		http.Error(w, "You can only change your own lists", http.StatusForbidden)
+		return
+	}
+	if _, err := syntax.ParseDID(subject); err != nil {
+		http.Error(w, "invalid did", http.StatusBadRequest)
+		return
+	}
+
+	row := ListMembershipVM{ListURI: list, Name: r.FormValue("name"), Did: subject}
+	switch r.FormValue("action") {
+	case "add":
+		item := &bsky.GraphListitem{
+			CreatedAt: syntax.DatetimeNow().String(),
+			List:      list,
+			Subject:   subject,
+		}
+		out, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
+			Collection: listItemCollection,
+			Repo:       didStr,
+			Record:     &util.LexiconTypeDecoder{Val: item},
+		})
+		if err != nil {
+			log.Printf("DEBUG: handleListMember - error adding %s to %s: %v", subject, list, err)
+			http.Error(w, "Failed to add to list: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		row.ItemURI = out.Uri
+	case "remove":
+		item, err := syntax.ParseATURI(r.FormValue("item"))
+		if err != nil || item.Authority().String() != didStr || item.Collection().String() != listItemCollection || item.RecordKey() == "" {
+			http.Error(w, "invalid list item", http.StatusBadRequest)
+			return
+		}
+		if _, err := atproto.RepoDeleteRecord(r.Context(), c, &atproto.RepoDeleteRecord_Input{
+			Collection: listItemCollection,
+			Repo:       didStr,
+			Rkey:       item.RecordKey().String(),
+		}); err != nil {
+			log.Printf("DEBUG: handleListMember - error removing %s from %s: %v", subject, list, err)
+			http.Error(w, "Failed to remove from list: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if r.FormValue("from") == "members" {
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "list_membership", row); err != nil {
+		log.Printf("DEBUG: handleListMember - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// htmxSidebarLists renders the signed-in user's lists for the sidebar.
+func htmxSidebarLists(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	lists, err := fetchCurateLists(r.Context(), c, didStr)
+	if err != nil {
+		log.Printf("DEBUG: htmxSidebarLists - getLists error: %v", err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "sidebar_lists", lists); err != nil {
+		log.Printf("DEBUG: htmxSidebarLists - Template error: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// maxGroupActors is how many actors a grouped notification names before "and N others".
+const maxGroupActors = 3
+
+// NotificationGroup is one row of the notifications page. Likes and reposts of the same post
+// are folded into a single group listing every actor; other reasons get a group each.
+type NotificationGroup struct {
+	Reason    string
+	Actors    []*bsky.ActorDefs_ProfileView
+	IndexedAt string
+	IsRead    bool
+	// Subject is the liked/reposted post, hydrated from ReasonSubject.
+	Subject *bsky.FeedDefs_PostView
+	// Post is the reply/mention/quote that triggered the notification.
+	Post *bsky.FeedDefs_PostView
+	// PriorActors is set when the group continues a row from the previous page: it's how many
+	// actors that row already lists. Such groups are rendered as updates to the existing row.
+	PriorActors int
+
+	key        string
+	subjectURI string
+	postURI    string
+}
+
+// NamedActors returns the actors named in the row; the rest are summed up by OtherActors.
+// For a continued group these are only the names the existing row still has room for.
+func (g *NotificationGroup) NamedActors() []*bsky.ActorDefs_ProfileView {
+	if n := max(maxGroupActors-g.PriorActors, 0); len(g.Actors) > n {
+		return g.Actors[:n]
+	}
+	return g.Actors
+}
+
+// OtherActors is the number of actors not named in the row.
+func (g *NotificationGroup) OtherActors() int {
+	return max(g.PriorActors+len(g.Actors)-maxGroupActors, 0)
+}
+
+// ElementID is the DOM id of a grouped row, so a later page can update it. Empty for
+// notifications that aren't grouped.
+func (g *NotificationGroup) ElementID() string {
+	if g.key == "" {
+		return ""
+	}
+	h := fnv.New64a()
+	h.Write([]byte(g.key))
+	return fmt.Sprintf("notif-%x", h.Sum64())
+}
+
+// NotificationsList is a page of grouped notifications plus the cursor for the next page.
+type NotificationsList struct {
+	Groups []*NotificationGroup
+	Cursor string
+	// ViewerDid is the signed-in user's DID, as in PostsList.
+	ViewerDid string
+	// Continued is set on "Load more" pages, which are appended below earlier groups.
+	Continued bool
+	// LastKey and LastActors describe the group holding the page's oldest notification, which
+	// the next page may continue.
+	LastKey    string
+	LastActors int
+}
+
+// MoreURL is the "Load more" request for the next page. It carries the group at the end of
+// this page so a continuation of it is folded into the existing row rather than repeated.
+func (l NotificationsList) MoreURL() string {
+	q := url.Values{"cursor": {l.Cursor}}
+	if l.LastKey != "" {
+		q.Set("last", l.LastKey)
+		q.Set("lastn", strconv.Itoa(l.LastActors))
+	}
+	return "/htmx/notifications?" + q.Encode()
+}
+
+// groupKey is the key likes and reposts of the same subject are grouped under, or "" for
+// notifications that aren't grouped.
+func groupKey(n *bsky.NotificationListNotifications_Notification) string {
+	if (n.Reason != "like" && n.Reason != "repost") || n.ReasonSubject == nil || *n.ReasonSubject == "" {
+		return ""
+	}
+	return n.Reason + " " + *n.ReasonSubject
+}
+
+// groupNotifications folds likes and reposts on the same subject into one group, keeping the
+// order of the newest notification in each group.
+func groupNotifications(notifs []*bsky.NotificationListNotifications_Notification) []*NotificationGroup {
+	var groups []*NotificationGroup
+	byKey := map[string]*NotificationGroup{}
+	for _, n := range notifs {
+		if n == nil || n.Author == nil {
+			continue
+		}
+		subject := ""
+		if n.ReasonSubject != nil {
+			subject = *n.ReasonSubject
+		}
+		key := groupKey(n)
+		if key != "" {
+			if g, ok := byKey[key]; ok {
+				g.Actors = append(g.Actors, n.Author)
+				g.IsRead = g.IsRead && n.IsRead
+				continue
+			}
+		}
+		g := &NotificationGroup{
+			Reason:    n.Reason,
+			Actors:    []*bsky.ActorDefs_ProfileView{n.Author},
+			IndexedAt: n.IndexedAt,
+			IsRead:    n.IsRead,
+			key:       key,
+		}
+		switch n.Reason {
+		case "like", "repost":
+			g.subjectURI = subject
+		case "reply", "mention", "quote":
+			g.postURI = n.Uri
+		}
+		if key != "" {
+			byKey[key] = g
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// hydrateNotificationGroups fills in the subject and triggering posts of each group.
+func hydrateNotificationGroups(ctx context.Context, c *client.APIClient, groups []*NotificationGroup) {
+	seen := map[string]struct{}{}
+	var uris []string
+	for _, g := range groups {
+		for _, u := range []string{g.subjectURI, g.postURI} {
+			if u == "" {
+				continue
+			}
+			if _, ok := seen[u]; !ok {
+				seen[u] = struct{}{}
+				uris = append(uris, u)
+			}
+		}
+	}
+
+	// API limits 25 URIs per request
+	posts := map[string]*bsky.FeedDefs_PostView{}
+	const batchSize = 25
+	for i := 0; i < len(uris); i += batchSize {
+		end := min(i+batchSize, len(uris))
+		postsMap, err := fetchPostsBatch(ctx, c, uris[i:end])
+		if err != nil {
+			log.Printf("DEBUG: hydrateNotificationGroups - fetchPostsBatch error: %v", err)
+			continue
+		}
+		for uri, pv := range postsMap {
+			posts[uri] = pv
+		}
+	}
+
+	for _, g := range groups {
+		g.Subject = posts[g.subjectURI]
+		g.Post = posts[g.postURI]
+	}
+}
+
+// fetchNotifications loads one page of notifications, grouped and hydrated. lastKey and
+// lastActors come from the previous page's NotificationsList; the group continuing it is
+// marked with PriorActors.
+func fetchNotifications(ctx context.Context, c *client.APIClient, cursor, lastKey string, lastActors int) (NotificationsList, error) {
+	out, err := bsky.NotificationListNotifications(ctx, c, cursor, 50, false, nil, "")
+	if err != nil {
+		return NotificationsList{}, err
+	}
+	groups := groupNotifications(out.Notifications)
+	hydrateNotificationGroups(ctx, c, groups)
+	list := NotificationsList{Groups: groups, Continued: cursor != ""}
+	if out.Cursor != nil {
+		list.Cursor = *out.Cursor
+	}
+	continueNotificationGroups(&list, out.Notifications, lastKey, lastActors)
+	return list, nil
+}
+
+// continueNotificationGroups links list to the pages around it: the group continuing the
+// previous page's last group gets PriorActors, and LastKey/LastActors are set from the
+// group of the oldest notification for the next page.
+func continueNotificationGroups(list *NotificationsList, notifs []*bsky.NotificationListNotifications_Notification, lastKey string, lastActors int) {
+	if lastKey != "" && lastActors > 0 {
+		for _, g := range list.Groups {
+			if g.key == lastKey {
+				g.PriorActors = lastActors
+			}
+		}
+	}
+	for i := len(notifs) - 1; i >= 0; i-- {
+		if notifs[i] == nil || notifs[i].Author == nil {
+			continue
+		}
+		key := groupKey(notifs[i])
+		for _, g := range list.Groups {
+			if key != "" && g.key == key {
+				list.LastKey, list.LastActors = key, g.PriorActors+len(g.Actors)
+			}
+		}
+		break
+	}
+}
+
+// handleNotifications renders the notifications page and marks notifications as seen.
+func handleNotifications(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	notifications, err := fetchNotifications(r.Context(), c, "", "", 0)
+	if err != nil {
+		log.Printf("DEBUG: handleNotifications - Error fetching notifications: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notifications.ViewerDid = didStr
+
+	// mark as seen after listing, so this render still highlights what was unread
+	if err := bsky.NotificationUpdateSeen(r.Context(), c, &bsky.NotificationUpdateSeen_Input{SeenAt: syntax.DatetimeNow().String()}); err != nil {
+		log.Printf("DEBUG: handleNotifications - updateSeen error: %v", err)
+	}
+
+	followsList := fetchFollows(r.Context(), c, didStr, 50)
+
+	data := NotificationsPageData{
+		Title:         "Replies - Tuiter 2006",
+		Profile:       profile,
+		Follows:       followsList,
+		Notifications: notifications,
+		SignedIn:      profile,
+	}
+
+	executeTemplate(w, "notifications.html", data)
+}
+
+// htmxNotifications returns the next page of notifications for the "Load more" button.
+func htmxNotifications(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	lastActors, _ := strconv.Atoi(q.Get("lastn"))
+	notifications, err := fetchNotifications(r.Context(), c, q.Get("cursor"), q.Get("last"), lastActors)
+	if err != nil {
+		log.Printf("DEBUG: htmxNotifications - Error fetching notifications: %v", err)
+		http.Error(w, "Failed to load notifications", http.StatusInternalServerError)
+		return
+	}
+	notifications.ViewerDid = didStr
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "notifications_list", notifications); err != nil {
+		log.Printf("DEBUG: htmxNotifications - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tpl.ExecuteTemplate(w, "notifications_more", notifications); err != nil {
+		log.Printf("DEBUG: htmxNotifications - failed to execute notifications_more template: %v", err)
+		fmt.Fprint(w, `<div id="notifications-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
+
+// htmxUnreadCount renders the unread notifications badge shown in the header.
+func htmxUnreadCount(w http.ResponseWriter, r *http.Request) {
+	c, _, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	out, err := bsky.NotificationGetUnreadCount(r.Context(), c, false, "")
+	if err != nil {
+		log.Printf("DEBUG: htmxUnreadCount - Error fetching unread count: %v", err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if out.Count > 0 {
+		fmt.Fprintf(w, `<span class="unread-badge">%d</span>`, out.Count)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+)
+
+func testNotification(reason, author, subject string) *bsky.NotificationListNotifications_Notification {
+	n := &bsky.NotificationListNotifications_Notification{
+		Reason: reason,
+		Author: &bsky.ActorDefs_ProfileView{Did: "did:plc:" + author, Handle: author + ".test"},
+		Uri:    "at://did:plc:" + author + "/app.bsky.feed.post/1",
+	}
+	if subject != "" {
+		n.ReasonSubject = &subject
+	}
+	return n
+}
+
+func TestContinueNotificationGroups(t *testing.T) {
+	post := "at://did:plc:me/app.bsky.feed.post/1"
+	page1 := []*bsky.NotificationListNotifications_Notification{
+		testNotification("follow", "zed", ""),
+		testNotification("like", "ann", post),
+		testNotification("reply", "yan", ""),
+		testNotification("like", "bob", post),
+	}
+	first := NotificationsList{Groups: groupNotifications(page1)}
+	continueNotificationGroups(&first, page1, "", 0)
+	if first.LastKey != "like "+post || first.LastActors != 2 {
+		t.Fatalf("page 1 last = %q/%d, want the like group with 2 actors", first.LastKey, first.LastActors)
+	}
+
+	page2 := []*bsky.NotificationListNotifications_Notification{
+		testNotification("like", "cat", post),
+		testNotification("follow", "dan", ""),
+		testNotification("like", "eve", post),
+	}
+	second := NotificationsList{Groups: groupNotifications(page2), Continued: true}
+	continueNotificationGroups(&second, page2, first.LastKey, first.LastActors)
+	if len(second.Groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(second.Groups))
+	}
+	like := second.Groups[0]
+	if like.PriorActors != 2 || like.ElementID() != first.Groups[1].ElementID() {
+		t.Errorf("like group PriorActors=%d id=%s, want 2 and %s", like.PriorActors, like.ElementID(), first.Groups[1].ElementID())
+	}
+	if named := like.NamedActors(); len(named) != 1 || named[0].Handle != "cat.test" {
+		t.Errorf("named actors = %v, want only cat", named)
+	}
+	if like.OtherActors() != 1 {
+		t.Errorf("OtherActors = %d, want 1", like.OtherActors())
+	}
+	if second.LastKey != first.LastKey || second.LastActors != 4 {
+		t.Errorf("page 2 last = %q/%d, want the like group with 4 actors", second.LastKey, second.LastActors)
+	}
+
+	setupHandlers(t)
+	var sb strings.Builder
+	if err := tpl.ExecuteTemplate(&sb, "notifications_list", second); err != nil {
+		t.Fatal(err)
+	}
+	body := sb.String()
+	id := like.ElementID()
+	for _, want := range []string{
+		`hx-swap-oob="beforeend:#` + id + `-actors"`,
+		`cat.test`,
+		`<span id="` + id + `-others" hx-swap-oob="true"> and 1 other</span>`,
+		`dan.test`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "eve.test") || strings.Contains(body, `id="`+id+`"`) {
+		t.Errorf("continued group was rendered as a new row:\n%s", body)
+	}
+	if !strings.Contains(second.MoreURL(), "lastn=4") {
+		t.Errorf("MoreURL = %s", second.MoreURL())
+	}
+}
+
+func TestNotificationsEmptyState(t *testing.T) {
+	setupHandlers(t)
+	for _, tt := range []struct {
+		list NotificationsList
+		want bool
+	}{
+		{NotificationsList{}, true},
+		{NotificationsList{Continued: true}, false},
+	} {
+		var sb strings.Builder
+		if err := tpl.ExecuteTemplate(&sb, "notifications_list", tt.list); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(sb.String(), "Nothing new"); got != tt.want {
+			t.Errorf("Continued=%v: empty state shown = %v, want %v", tt.list.Continued, got, tt.want)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/bluesky-social/indigo/api/atproto"
 	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/lex/util"
 )
 
@@ -17,9 +21,21 @@ func handlePostStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == http.MethodPost {
+		images, code, err := imagesFromRequest(w, r, c)
+		if err != nil {
+			log.Printf("DEBUG: handlePostStatus - Error reading images: %v", err)
+			http.Error(w, err.Error(), code)
+			return
+		}
 		status := r.FormValue("status")
-		if status != "" {
-			post := &bsky.FeedPost{Text: status}
+		quote := quoteRefFromForm(r)
+		if status != "" || images != nil || quote != nil {
+			post := &bsky.FeedPost{
+				Text:      status,
+				CreatedAt: syntax.DatetimeNow().String(),
+				Facets:    detectFacets(r.Context(), c, status),
+				Embed:     buildPostEmbed(quote, images, nil),
+			}
 			if _, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
 				Collection: "app.bsky.feed.post",
 				Repo:       didStr,
@@ -29,6 +45,10 @@ func handlePostStatus(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
+			invalidateProfile(didStr)
+			if quote != nil {
+				invalidatePost(quote.Uri)
+			}
 		}
 		http.Redirect(w, r, "/timeline", http.StatusFound)
 		return
@@ -49,6 +69,15 @@ func handlePostStatus(w http.ResponseWriter, r *http.Request) {
 		Follows:     followsList,
 		SignedIn:    profile,
 	}
+	// opened from a Quote button on a page without a post box
+	if uri := r.URL.Query().Get("quote"); uri != "" {
+		if pv, err := fetchPost(r.Context(), c, uri); err != nil {
+			log.Printf("DEBUG: handlePostStatus - error fetching quoted post %s: %v", uri, err)
+			data.ErrorMsg = "The post to quote could not be found"
+		} else {
+			data.Quote = pv
+		}
+	}
 
 	executeTemplate(w, "post-status.html", data)
 }
@@ -65,13 +94,37 @@ func handleTimelinePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	images, code, err := imagesFromRequest(w, r, c)
+	if err != nil {
+		log.Printf("DEBUG: handleTimelinePost - Error reading images: %v", err)
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	video, err := videoEmbedFromForm(r.Context(), c, r)
+	if err != nil {
+		log.Printf("DEBUG: handleTimelinePost - Error reading video: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if images != nil && video != nil {
+		http.Error(w, "A post can have images or a video, not both", http.StatusBadRequest)
+		return
+	}
+
 	status := r.FormValue("status")
-	if status == "" {
+	quote := quoteRefFromForm(r)
+	if status == "" && quote == nil && images == nil && video == nil {
 		http.Error(w, "Status cannot be empty", http.StatusBadRequest)
 		return
 	}
 
-	post := &bsky.FeedPost{Text: status}
+	post := &bsky.FeedPost{
+		Text:      status,
+		CreatedAt: syntax.DatetimeNow().String(),
+		Facets:    detectFacets(r.Context(), c, status),
+		Embed:     buildPostEmbed(quote, images, video),
+	}
 	resp, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
 		Collection: "app.bsky.feed.post",
 		Repo:       didStr,
@@ -82,9 +135,13 @@ func handleTimelinePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Println("Created post:", resp.Uri)
+	invalidateProfile(didStr)
+	if quote != nil {
+		invalidatePost(quote.Uri)
+	}
 
 	w.Header().Set("Content-Type", "text/html")
-	timeline, err := bsky.FeedGetTimeline(r.Context(), c, "", "", 50)
+	timeline, err := fetchTimeline(r.Context(), c, didStr, "")
 	if err != nil {
 		http.Error(w, "Failed to load timeline", http.StatusInternalServerError)
 		return
@@ -93,9 +150,181 @@ func handleTimelinePost(w http.ResponseWriter, r *http.Request) {
 	// fetch signed-in profile for template context
 	signedInProfile, _ := fetchProfile(r.Context(), c, didStr)
 
-	data := TimelinePartialData{Timeline: timeline, Posts: PostsList{Items: timeline.Feed, Cursor: getCursorFromTimeline(timeline)}, SignedIn: signedInProfile}
+	data := TimelinePartialData{Timeline: timeline, Posts: hydratePostsList(r.Context(), c, timeline.Feed, getCursorFromTimeline(timeline), didStr), SignedIn: signedInProfile}
 	if err := tpl.ExecuteTemplate(w, "timeline_posts_partial.html", data); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+
+	// the video slot lives outside the swapped timeline, so reset it out-of-band
+	if video != nil {
+		if err := tpl.ExecuteTemplate(w, "post_box_video", VideoStatusVM{OOB: true}); err != nil {
+			log.Printf("DEBUG: handleTimelinePost - failed to reset video slot: %v", err)
+		}
+	}
+}
+
+// handleQuote renders the quote preview that gets embedded into the post box when the
+// "Quote" action is clicked on a post. The fragment carries the quoted post's strong ref
+// as hidden inputs so handleTimelinePost can build the embed on submit.
+func handleQuote(w http.ResponseWriter, r *http.Request) {
+	c, _, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	uri := r.URL.Query().Get("uri")
+	if uri == "" {
+		http.Error(w, "uri is required", http.StatusBadRequest)
+		return
+	}
+
+	pv, err := fetchPost(r.Context(), c, uri)
+	if err != nil {
+		log.Printf("DEBUG: handleQuote - error fetching post %s: %v", uri, err)
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "quote_compose", pv); err != nil {
+		log.Printf("DEBUG: handleQuote - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// quoteRefFromForm returns the strong ref of the post being quoted, if the post box carries one.
+func quoteRefFromForm(r *http.Request) *atproto.RepoStrongRef {
+	uri := r.FormValue("quote-uri")
+	cid := r.FormValue("quote-cid")
+	if uri == "" || cid == "" {
+		return nil
+	}
+	return &atproto.RepoStrongRef{Uri: uri, Cid: cid}
+}
+
+// buildPostEmbed assembles the embed for a new post. A quoted record alone becomes an
+// app.bsky.embed.record, images or a video alone an app.bsky.embed.images or
+// app.bsky.embed.video, and a quote with media an app.bsky.embed.recordWithMedia.
+// Returns nil when there is nothing to embed.
+func buildPostEmbed(quote *atproto.RepoStrongRef, images *bsky.EmbedImages, video *bsky.EmbedVideo) *bsky.FeedPost_Embed {
+	var media *bsky.EmbedRecordWithMedia_Media
+	switch {
+	case images != nil && len(images.Images) > 0:
+		media = &bsky.EmbedRecordWithMedia_Media{EmbedImages: images}
+	case video != nil:
+		media = &bsky.EmbedRecordWithMedia_Media{EmbedVideo: video}
+	}
+	switch {
+	case quote != nil && media != nil:
+		return &bsky.FeedPost_Embed{EmbedRecordWithMedia: &bsky.EmbedRecordWithMedia{
+			Record: &bsky.EmbedRecord{LexiconTypeID: "app.bsky.embed.record", Record: quote},
+			Media:  media,
+		}}
+	case quote != nil:
+		return &bsky.FeedPost_Embed{EmbedRecord: &bsky.EmbedRecord{Record: quote}}
+	case media != nil && media.EmbedImages != nil:
+		return &bsky.FeedPost_Embed{EmbedImages: media.EmbedImages}
+	case media != nil:
+		return &bsky.FeedPost_Embed{EmbedVideo: media.EmbedVideo}
+	default:
+		return nil
+	}
+}
+
+// handleComposeVideo receives the video picked in the post box, hands it to the video
+// service and responds with the post_box_video slot, which polls handleComposeVideoStatus
+// until processing finishes.
+func handleComposeVideo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxVideoBytes+1<<20)
+	file, fh, err := r.FormFile("video")
+	if err != nil {
+		renderVideoSlot(w, VideoStatusVM{Error: "Please choose a video file"})
+		return
+	}
+	defer file.Close()
+	if fh.Size > maxVideoBytes {
+		renderVideoSlot(w, VideoStatusVM{Error: fmt.Sprintf("%s is too large (max %d MB)", fh.Filename, maxVideoBytes>>20)})
+		return
+	}
+
+	// trust the bytes, not the client-declared Content-Type
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	mimeType := http.DetectContentType(head[:n])
+	if !allowedVideoTypes[mimeType] {
+		renderVideoSlot(w, VideoStatusVM{Error: fmt.Sprintf("%s: unsupported video type %s", fh.Filename, mimeType)})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	job, err := videoService.UploadVideo(r.Context(), c, didStr, fh.Filename, mimeType, file)
+	if err != nil {
+		log.Printf("DEBUG: handleComposeVideo - upload error: %v", err)
+		renderVideoSlot(w, VideoStatusVM{Error: "Video upload failed"})
+		return
+	}
+	vm, err := newVideoStatusVM(job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderVideoSlot(w, vm)
+}
+
+// handleComposeVideoStatus is polled by the post box while a video is being processed.
+func handleComposeVideoStatus(w http.ResponseWriter, r *http.Request) {
+	if _, _, err := getClientFromSession(r.Context(), r); err != nil {
+		http.Error(w, "not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	jobID := r.URL.Query().Get("job")
+	if jobID == "" {
+		http.Error(w, "job is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := videoService.GetJobStatus(r.Context(), jobID)
+	if err != nil {
+		failures, _ := strconv.Atoi(r.URL.Query().Get("failures"))
+		failures++
+		log.Printf("DEBUG: handleComposeVideoStatus - error %d for job %s: %v", failures, jobID, err)
+		if failures >= maxVideoStatusFailures {
+			renderVideoSlot(w, VideoStatusVM{JobID: jobID, Error: "Couldn't check on the video, please try again"})
+			return
+		}
+		// keep polling; the service may be briefly unavailable
+		renderVideoSlot(w, VideoStatusVM{JobID: jobID, Failures: failures})
+		return
+	}
+	vm, err := newVideoStatusVM(job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderVideoSlot(w, vm)
+}
+
+func renderVideoSlot(w http.ResponseWriter, vm VideoStatusVM) {
+	w.Header().Set("Content-Type", "text/html")
+	if err := tpl.ExecuteTemplate(w, "post_box_video", vm); err != nil {
+		log.Printf("DEBUG: renderVideoSlot - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+// searchQuery is a post search split into free text and the operators Tuiter understands:
+// from:, mentions:, since:, until: and lang:. Unknown operators stay in the text.
+type searchQuery struct {
+	Text     string
+	Author   string
+	Mentions string
+	Since    string
+	Until    string
+	Lang     string
+}
+
+// parseSearchQuery extracts search operators from a raw query. "from:me" and "mentions:me"
+// refer to the signed-in handle; a leading @ on handles is optional.
+func parseSearchQuery(raw, myHandle string) searchQuery {
+	var q searchQuery
+	var text []string
+	for _, field := range strings.Fields(raw) {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			text = append(text, field)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "from":
+			q.Author = searchHandle(value, myHandle)
+		case "mentions":
+			q.Mentions = searchHandle(value, myHandle)
+		case "since":
+			q.Since = value
+		case "until":
+			q.Until = value
+		case "lang":
+			q.Lang = value
+		default:
+			text = append(text, field)
+		}
+	}
+	q.Text = strings.Join(text, " ")
+	return q
+}
+
+func searchHandle(value, myHandle string) string {
+	value = strings.TrimPrefix(value, "@")
+	if strings.EqualFold(value, "me") && myHandle != "" {
+		return myHandle
+	}
+	return value
+}
+
+// searchSort normalizes the sort query parameter; Tuiter defaults to latest, like a timeline.
+func searchSort(v string) string {
+	if v == "top" {
+		return "top"
+	}
+	return "latest"
+}
+
+// searchPosts runs app.bsky.feed.searchPosts and wraps the results as a PostsList with parent
+// previews, so they render like any other feed.
+func searchPosts(ctx context.Context, c *client.APIClient, raw, myHandle, sort, cursor string) (PostsList, error) {
+	q := parseSearchQuery(raw, myHandle)
+	text := q.Text
+	if text == "" {
+		// operator-only searches: the appview understands the operators in q as well
+		text = raw
+	}
+	out, err := bsky.FeedSearchPosts(ctx, c, q.Author, cursor, "", q.Lang, 50, q.Mentions, text, q.Since, sort, nil, q.Until, "")
+	if err != nil {
+		return PostsList{}, err
+	}
+	return searchResultsList(ctx, c, out), nil
+}
+
+// searchResultsList converts searchPosts output (bare post views) into feed items.
+func searchResultsList(ctx context.Context, c *client.APIClient, out *bsky.FeedSearchPosts_Output) PostsList {
+	items := make([]*bsky.FeedDefs_FeedViewPost, 0, len(out.Posts))
+	for _, pv := range out.Posts {
+		if pv != nil {
+			items = append(items, &bsky.FeedDefs_FeedViewPost{Post: pv})
+		}
+	}
+	list := hydratePostsList(ctx, c, items, "", "")
+	if out.Cursor != nil {
+		list.Cursor = *out.Cursor
+	}
+	return list
+}
+
+// handleSearch renders /search?q=...&sort=top|latest.
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	sort := searchSort(r.URL.Query().Get("sort"))
+	data := SearchPageData{
+		Title:    "Search - Tuiter 2006",
+		Query:    query,
+		Sort:     sort,
+		Profile:  profile,
+		Follows:  fetchFollows(r.Context(), c, didStr, 50),
+		SignedIn: profile,
+	}
+	if query != "" {
+		posts, err := searchPosts(r.Context(), c, query, profile.Handle, sort, "")
+		if err != nil {
+			log.Printf("DEBUG: handleSearch - searchPosts error for %q: %v", query, err)
+			data.ErrorMsg = "Search failed, please try again."
+		}
+		posts.ViewerDid = didStr
+		data.Posts = posts
+	}
+
+	executeTemplate(w, "search.html", data)
+}
+
+// htmxSearch returns the next page of search results for the "Load more" button.
+func htmxSearch(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	sort := searchSort(r.URL.Query().Get("sort"))
+	myHandle := ""
+	if me, err := fetchProfile(r.Context(), c, didStr); err == nil {
+		myHandle = me.Handle
+	}
+	posts, err := searchPosts(r.Context(), c, query, myHandle, sort, r.URL.Query().Get("cursor"))
+	if err != nil {
+		log.Printf("DEBUG: htmxSearch - searchPosts error for %q: %v", query, err)
+		http.Error(w, "Failed to load results", http.StatusInternalServerError)
+		return
+	}
+	posts.ViewerDid = didStr
+
+	w.Header().Set("Content-Type", "text/html")
+	data := SearchPageData{Query: query, Sort: sort, Posts: posts}
+	if err := tpl.ExecuteTemplate(w, "posts_list_partial.html", data); err != nil {
+		log.Printf("DEBUG: htmxSearch - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "search_more", data); err != nil {
+		log.Printf("DEBUG: htmxSearch - failed to execute search_more template: %v", err)
+		fmt.Fprint(w, `<div id="search-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
+
+// publicAppViewHost serves unauthenticated app.bsky queries, used by the sign-in typeahead.
+const publicAppViewHost = "https://public.api.bsky.app"
+
+// ActorsList is a page of profiles plus the cursor for the next page.
+type ActorsList struct {
+	Actors []*bsky.ActorDefs_ProfileView
+	Cursor string
+	// ViewerDid is the signed-in user's DID, used to hide the follow button on their own row.
+	ViewerDid string
+}
+
+// handleSearchPeople renders /search/people?q=... backed by app.bsky.actor.searchActors.
+func handleSearchPeople(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	data := SearchPageData{
+		Title:    "Search people - Tuiter 2006",
+		Query:    query,
+		Profile:  profile,
+		Follows:  fetchFollows(r.Context(), c, didStr, 50),
+		SignedIn: profile,
+	}
+	if query != "" {
+		out, err := bsky.ActorSearchActors(r.Context(), c, "", 50, strings.TrimPrefix(query, "@"), "")
+		if err != nil {
+			log.Printf("DEBUG: handleSearchPeople - searchActors error for %q: %v", query, err)
+			data.ErrorMsg = "Search failed, please try again."
+		} else {
+			data.People = actorsListFromSearch(out, didStr)
+		}
+	}
+
+	executeTemplate(w, "search_people.html", data)
+}
+
+// htmxSearchPeople returns the next page of people results for the "Load more" button.
+func htmxSearchPeople(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	out, err := bsky.ActorSearchActors(r.Context(), c, r.URL.Query().Get("cursor"), 50, strings.TrimPrefix(query, "@"), "")
+	if err != nil {
+		log.Printf("DEBUG: htmxSearchPeople - searchActors error for %q: %v", query, err)
+		http.Error(w, "Failed to load results", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	data := SearchPageData{Query: query, People: actorsListFromSearch(out, didStr)}
+	if err := tpl.ExecuteTemplate(w, "actors_list", data.People); err != nil {
+		log.Printf("DEBUG: htmxSearchPeople - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "search_people_more", data); err != nil {
+		log.Printf("DEBUG: htmxSearchPeople - failed to execute search_people_more template: %v", err)
+		fmt.Fprint(w, `<div id="search-people-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
+
+func actorsListFromSearch(out *bsky.ActorSearchActors_Output, viewerDid string) ActorsList {
+	list := ActorsList{Actors: out.Actors, ViewerDid: viewerDid}
+	if out.Cursor != nil {
+		list.Cursor = *out.Cursor
+	}
+	return list
+}
+
+// htmxTypeahead suggests accounts for a partial handle or name, for @mentions in the post box
+// and for the sign-in form. Signed-out requests go to the public appview. The partial is read
+// from q, or from identifier when the sign-in input triggers the request itself.
+func htmxTypeahead(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		q = r.URL.Query().Get("identifier")
+	}
+	q = strings.TrimPrefix(strings.TrimSpace(q), "@")
+
+	w.Header().Set("Content-Type", "text/html")
+	if q == "" {
+		return
+	}
+
+	var lc *client.APIClient
+	if c, _, err := getClientFromSession(r.Context(), r); err == nil {
+		lc = c
+	} else {
+		lc = client.NewAPIClient(publicAppViewHost)
+	}
+	out, err := bsky.ActorSearchActorsTypeahead(r.Context(), lc, 8, q, "")
+	if err != nil {
+		log.Printf("DEBUG: htmxTypeahead - searchActorsTypeahead error for %q: %v", q, err)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "typeahead", out.Actors); err != nil {
+		log.Printf("DEBUG: htmxTypeahead - Template error: %v", err)
+	}
+}
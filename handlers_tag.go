@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+// tagFromPath returns the hashtag of a /tag/{name} path, without its leading #.
+func tagFromPath(path string) string {
+	tag := strings.Trim(strings.TrimPrefix(path, "/tag/"), "/")
+	return strings.TrimLeft(tag, "#＃")
+}
+
+// fetchTagPosts lists the latest posts carrying a hashtag, plus the appview's hit count when
+// it reports one.
+func fetchTagPosts(ctx context.Context, c *client.APIClient, tag, cursor string) (PostsList, *int64, error) {
+	out, err := bsky.FeedSearchPosts(ctx, c, "", cursor, "", "", 50, "", "#"+tag, "", "latest", []string{tag}, "", "")
+	if err != nil {
+		return PostsList{}, nil, err
+	}
+	return searchResultsList(ctx, c, out), out.HitsTotal, nil
+}
+
+// tagPostCount describes how many recent posts carry the tag, e.g. "12" or "50+" when the
+// appview doesn't report a total and there are more pages.
+func tagPostCount(posts PostsList, hits *int64) string {
+	if hits != nil {
+		return fmt.Sprint(*hits)
+	}
+	if posts.Cursor != "" {
+		return fmt.Sprintf("%d+", len(posts.Items))
+	}
+	return fmt.Sprint(len(posts.Items))
+}
+
+// handleTag renders /tag/{name}: the latest posts with that hashtag.
+func handleTag(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	tag := tagFromPath(r.URL.Path)
+	if tag == "" {
+		http.Redirect(w, r, "/search", http.StatusFound)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := TagPageData{
+		Title:    "#" + tag + " - Tuiter 2006",
+		Tag:      tag,
+		Profile:  profile,
+		Follows:  fetchFollows(r.Context(), c, didStr, 50),
+		SignedIn: profile,
+	}
+	posts, hits, err := fetchTagPosts(r.Context(), c, tag, "")
+	if err != nil {
+		log.Printf("DEBUG: handleTag - searchPosts error for #%s: %v", tag, err)
+		data.ErrorMsg = "Could not load updates for this tag, please try again."
+	}
+	posts.ViewerDid = didStr
+	data.Posts = posts
+	data.Count = tagPostCount(posts, hits)
+
+	executeTemplate(w, "tag.html", data)
+}
+
+// htmxTag returns the next page of a tag's posts for the "Load more" button.
+func htmxTag(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	tag := strings.TrimLeft(r.URL.Query().Get("tag"), "#＃")
+	posts, _, err := fetchTagPosts(r.Context(), c, tag, r.URL.Query().Get("cursor"))
+	if err != nil {
+		log.Printf("DEBUG: htmxTag - searchPosts error for #%s: %v", tag, err)
+		http.Error(w, "Failed to load updates", http.StatusInternalServerError)
+		return
+	}
+	posts.ViewerDid = didStr
+
+	w.Header().Set("Content-Type", "text/html")
+	data := TagPageData{Tag: tag, Posts: posts}
+	if err := tpl.ExecuteTemplate(w, "posts_list_partial.html", data); err != nil {
+		log.Printf("DEBUG: htmxTag - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := tpl.ExecuteTemplate(w, "tag_more", data); err != nil {
+		log.Printf("DEBUG: htmxTag - failed to execute tag_more template: %v", err)
+		fmt.Fprint(w, `<div id="tag-more" hx-swap-oob="innerHTML"></div>`)
+	}
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
 	"github.com/bluesky-social/indigo/api/atproto"
 	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/indigo/lex/util"
 )
 
@@ -17,103 +19,44 @@ func handleTimeline(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile, err := fetchProfile(r.Context(), c, didStr)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	timeline, err := bsky.FeedGetTimeline(r.Context(), c, "", "", 50)
+	ctx, cancel := withPageDeadline(r.Context())
+	defer cancel()
+
+	var (
+		profile     *bsky.ActorDefs_ProfileViewDetailed
+		timeline    *bsky.FeedGetTimeline_Output
+		postsList   PostsList
+		followsList []*bsky.ActorDefs_ProfileView
+		tabs        []FeedTab
+	)
+	err = assemblePage(ctx, "handleTimeline",
+		pageCall{Name: "profile", Run: func(ctx context.Context) (err error) {
+			profile, err = fetchProfile(ctx, c, didStr)
+			return err
+		}},
+		pageCall{Name: "timeline", Run: func(ctx context.Context) (err error) {
+			timeline, err = fetchTimeline(ctx, c, didStr, "")
+			if err != nil {
+				return err
+			}
+			postsList = hydratePostsList(ctx, c, timeline.Feed, getCursorFromTimeline(timeline), didStr)
+			return nil
+		}},
+		pageCall{Name: "follows", Optional: true, Run: func(ctx context.Context) error {
+			followsList = fetchFollows(ctx, c, didStr, 50)
+			return nil
+		}},
+		pageCall{Name: "feed tabs", Optional: true, Run: func(ctx context.Context) error {
+			savedFeeds, err := loadSavedFeeds(ctx, c)
+			tabs = feedTabs(savedFeeds, "", fetchFeedGenerators(ctx, c, pinnedFeedURIs(savedFeeds)))
+			return err
+		}},
+	)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	followsList := fetchFollows(r.Context(), c, didStr, 50)
-
-	// Collect all unique parent URIs referenced in the timeline so we can batch-fetch them once
-	parentURIsSet := map[string]struct{}{}
-	if timeline != nil && timeline.Feed != nil {
-		for _, fv := range timeline.Feed {
-			if fv == nil || fv.Post == nil {
-				continue
-			}
-			if uri := extractReplyParentURI(fv.Post); uri != "" {
-				parentURIsSet[uri] = struct{}{}
-			}
-			// also include any root refs from the post record if present
-			chain := GetReplyChainInfos(fv.Post)
-			for _, pi := range chain {
-				if pi.Uri != "" {
-					parentURIsSet[pi.Uri] = struct{}{}
-				}
-			}
-		}
-	}
-
-	var parentURIs []string
-	for u := range parentURIsSet {
-		parentURIs = append(parentURIs, u)
-	}
-
-	// Batch fetch parent posts (API limits 25 URIs per request)
-	parentPreviews := map[string]ParentInfo{}
-	if len(parentURIs) > 0 {
-		const batchSize = 25
-		for i := 0; i < len(parentURIs); i += batchSize {
-			end := i + batchSize
-			if end > len(parentURIs) {
-				end = len(parentURIs)
-			}
-			batch := parentURIs[i:end]
-			postsMap, err := fetchPostsBatch(r.Context(), c, batch)
-			if err != nil {
-				log.Printf("DEBUG: handleTimeline - fetchPostsBatch error: %v", err)
-				continue
-			}
-			for uri, pv := range postsMap {
-				if pv == nil {
-					continue
-				}
-				pi := ParentInfo{Uri: uri}
-				// fill author handle/name if present
-				if pv.Author != nil {
-					if pv.Author.DisplayName != nil && *pv.Author.DisplayName != "" {
-						pi.AuthorName = *pv.Author.DisplayName
-					} else if pv.Author.Handle != "" {
-						pi.AuthorName = pv.Author.Handle
-					}
-					if pv.Author.Handle != "" {
-						pi.AuthorHandle = pv.Author.Handle
-					}
-					// populate avatar if available
-					if pv.Author.Avatar != nil {
-						pi.Avatar = *pv.Author.Avatar
-					}
-				}
-				// fill text
-				pi.Text = getPostText(pv.Record)
-				// link URL and posted time if available
-				if pv.Uri != "" {
-					pi.PostURL = getPostURL(pv)
-				}
-				pi.IndexedAt = pv.IndexedAt
-				if m := GetPostMedia(pv); m != nil {
-					pi.Media = m
-				}
-				// like count if available
-				if pv.LikeCount != nil {
-					pi.LikeCount = int(*pv.LikeCount)
-				}
-				// viewer state: whether the signed-in viewer liked this post
-				pi.IsFav = getIsFav(pv)
-				parentPreviews[uri] = pi
-			}
-		}
-	}
-
-	postsList := PostsList{Items: timeline.Feed, Cursor: getCursorFromTimeline(timeline), ParentPreviews: parentPreviews}
-
 	data := TimelinePageData{
 		Title:         "Timeline - Tuiter 2006",
 		CurrentUser:   profile,
@@ -122,6 +65,7 @@ func handleTimeline(w http.ResponseWriter, r *http.Request) {
 		Follows:       followsList,
 		Posts:         postsList,
 		PostBoxHandle: "",
+		Tabs:          tabs,
 		// SignedIn should point to the logged-in profile
 		SignedIn: profile,
 	}
@@ -136,8 +80,13 @@ func handleReply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	isHtmx := r.Header.Get("HX-Request") == "true"
 	c, didStr, err := getClientFromSession(r.Context(), r)
 	if err != nil {
+		if isHtmx {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
 		http.Redirect(w, r, "/signin", http.StatusFound)
 		return
 	}
@@ -154,21 +103,18 @@ func handleReply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	postsView, err := bsky.FeedGetPosts(r.Context(), c, []string{replyTo})
-	if err != nil || len(postsView.Posts) == 0 {
+	parent, err := fetchPost(r.Context(), c, replyTo)
+	if err != nil {
 		log.Printf("DEBUG: handleReply - Error fetching original post: %v", err)
 		http.Error(w, "Original post not found", http.StatusNotFound)
 		return
 	}
 
-	postView := postsView.Posts[0]
 	post := &bsky.FeedPost{
 		Text:      status,
-		CreatedAt: "",
-		Reply: &bsky.FeedPost_ReplyRef{
-			Root:   &atproto.RepoStrongRef{Uri: postView.Uri, Cid: postView.Cid},
-			Parent: &atproto.RepoStrongRef{Uri: postView.Uri, Cid: postView.Cid},
-		},
+		CreatedAt: syntax.DatetimeNow().String(),
+		Facets:    detectFacets(r.Context(), c, status),
+		Reply:     buildReplyRef(parent),
 	}
 
 	resp, err := atproto.RepoCreateRecord(r.Context(), c, &atproto.RepoCreateRecord_Input{
@@ -180,7 +126,106 @@ func handleReply(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to create reply: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
 	log.Println("Created reply:", resp.Uri)
-	http.Redirect(w, r, "/post?uri="+replyTo, http.StatusFound)
+	invalidateProfile(didStr)
+	invalidatePost(post.Reply.Parent.Uri)
+	invalidatePost(post.Reply.Root.Uri)
+
+	if !isHtmx {
+		http.Redirect(w, r, getPostURL(parent), http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	if r.FormValue("mode") != "thread" {
+		// outside a thread page, just bump the reply button the reply was sent from
+		replyCount := 1
+		if parent.ReplyCount != nil {
+			replyCount = int(*parent.ReplyCount) + 1
+		}
+		data := map[string]interface{}{"Class": r.FormValue("class"), "Count": replyCount, "IsLeft": r.FormValue("left") == "true", "IsActive": true, "Uri": replyTo}
+		if err := tpl.ExecuteTemplate(w, "reply_button", data); err != nil {
+			log.Printf("DEBUG: handleReply - Template error: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Render the new reply as a thread node. The record was just written, so build the view
+	// locally instead of waiting for the appview to index it.
+	me, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		log.Printf("DEBUG: handleReply - error fetching signed-in profile: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	node := &bsky.FeedDefs_ThreadViewPost{Post: &bsky.FeedDefs_PostView{
+		Uri:       resp.Uri,
+		Cid:       resp.Cid,
+		Author:    &bsky.ActorDefs_ProfileViewBasic{Did: me.Did, Handle: me.Handle, DisplayName: me.DisplayName, Avatar: me.Avatar},
+		Record:    &util.LexiconTypeDecoder{Val: post},
+		IndexedAt: post.CreatedAt,
+	}}
+	if err := tpl.ExecuteTemplate(w, "thread_node", wrapThread(node, "", didStr)); err != nil {
+		log.Printf("DEBUG: handleReply - Template error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// buildReplyRef builds the reply ref for a reply to parent. The thread root is taken from the
+// parent's own reply ref when the parent is itself a reply, so deep replies stay in the thread.
+func buildReplyRef(parent *bsky.FeedDefs_PostView) *bsky.FeedPost_ReplyRef {
+	parentRef := &atproto.RepoStrongRef{Uri: parent.Uri, Cid: parent.Cid}
+	rootRef := parentRef
+	if parent.Record != nil {
+		if rec, ok := parent.Record.Val.(*bsky.FeedPost); ok && rec != nil && rec.Reply != nil && rec.Reply.Root != nil && rec.Reply.Root.Uri != "" {
+			rootRef = &atproto.RepoStrongRef{Uri: rec.Reply.Root.Uri, Cid: rec.Reply.Root.Cid}
+		}
+	}
+	return &bsky.FeedPost_ReplyRef{Root: rootRef, Parent: parentRef}
+}
+
+// handleTimelineFilters shows (GET) and saves (POST) the signed-in user's timeline filters.
+func handleTimelineFilters(w http.ResponseWriter, r *http.Request) {
+	c, didStr, err := getClientFromSession(r.Context(), r)
+	if err != nil {
+		http.Redirect(w, r, "/signin", http.StatusFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		f := TimelineFilters{
+			HideReposts:              r.FormValue("hide-reposts") != "",
+			HideRepliesToNonFollowed: r.FormValue("hide-replies") != "",
+			HideQuotes:               r.FormValue("hide-quotes") != "",
+			HideNoText:               r.FormValue("hide-no-text") != "",
+		}
+		if settingsStore == nil {
+			http.Error(w, "Settings are not available", http.StatusServiceUnavailable)
+			return
+		}
+		if err := settingsStore.SaveTimelineFilters(r.Context(), didStr, f); err != nil {
+			log.Printf("DEBUG: handleTimelineFilters - error saving filters for %s: %v", didStr, err)
+			http.Error(w, "Failed to save filters", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/timeline", http.StatusFound)
+		return
+	}
+
+	profile, err := fetchProfile(r.Context(), c, didStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := TimelineFiltersPageData{
+		Title:    "Timeline filters - Tuiter 2006",
+		Profile:  profile,
+		Follows:  fetchFollows(r.Context(), c, didStr, 50),
+		Filters:  loadTimelineFilters(r.Context(), didStr),
+		SignedIn: profile,
+	}
+
+	executeTemplate(w, "timeline_filters.html", data)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strings"
@@ -31,122 +32,95 @@ func handleProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := strings.TrimPrefix(r.URL.Path, "/profile/")
-	profileHandle := path
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/profile/"), "/")
+	profileHandle, page, _ := strings.Cut(path, "/")
 	if profileHandle == "" {
 		profileHandle = myDid
 	}
-
-	profileView, err := fetchProfile(r.Context(), c, profileHandle)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	switch page {
+	case "":
+	case "following", "followers":
+		handleConnections(w, r, c, myDid, profileHandle, page)
 		return
-	}
-	if profileView == nil {
-		http.Error(w, "Profile not found", http.StatusNotFound)
+	case "favorites":
+		handleFavorites(w, r, c, myDid, profileHandle)
+		return
+	default:
+		http.NotFound(w, r)
 		return
 	}
 
-	authorFeed, err := bsky.FeedGetAuthorFeed(r.Context(), c, profileView.Did, "", "", false, 50)
+	ctx, cancel := withPageDeadline(r.Context())
+	defer cancel()
+
+	// resolve the handle up front so the calls below can all run at once
+	did, err := resolveHandleToDID(ctx, c, profileHandle)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	myProfile, err := fetchProfile(r.Context(), c, myDid)
+	tab := profileTab(r.URL.Query().Get("tab"))
+	var (
+		profileView *bsky.ActorDefs_ProfileViewDetailed
+		pinned      *bsky.FeedDefs_FeedViewPost
+		authorFeed  *bsky.FeedGetAuthorFeed_Output
+		posts       PostsList
+		myProfile   *bsky.ActorDefs_ProfileViewDetailed
+		followsList []*bsky.ActorDefs_ProfileView
+	)
+	err = assemblePage(ctx, "handleProfile",
+		pageCall{Name: "profile", Run: func(ctx context.Context) (err error) {
+			profileView, err = fetchProfile(ctx, c, did)
+			if err != nil || tab != profileTabs[0] || profileView.PinnedPost == nil || profileView.PinnedPost.Uri == "" {
+				return err
+			}
+			if pv, err := fetchPost(ctx, c, profileView.PinnedPost.Uri); err != nil {
+				log.Printf("DEBUG: handleProfile - error fetching pinned post %s: %v", profileView.PinnedPost.Uri, err)
+			} else {
+				pinned = &bsky.FeedDefs_FeedViewPost{Post: pv}
+			}
+			return nil
+		}},
+		pageCall{Name: "author feed", Run: func(ctx context.Context) (err error) {
+			authorFeed, err = bsky.FeedGetAuthorFeed(ctx, c, did, "", tab.Filter, false, 50)
+			if err != nil {
+				return err
+			}
+			posts = hydratePostsList(ctx, c, authorFeed.Feed, getCursorFromAuthorFeed(authorFeed), myDid)
+			return nil
+		}},
+		pageCall{Name: "signed-in profile", Run: func(ctx context.Context) (err error) {
+			myProfile, err = fetchProfile(ctx, c, myDid)
+			return err
+		}},
+		pageCall{Name: "follows", Optional: true, Run: func(ctx context.Context) error {
+			followsList = fetchFollows(ctx, c, did, 50)
+			return nil
+		}},
+	)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	followsList := fetchFollows(r.Context(), c, profileView.Did, 50)
+	if profileView == nil {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
 
 	postBoxHandle := ""
 	if profileView.Handle != "" {
 		postBoxHandle = profileView.Handle
 	}
 
-	// Collect parent URIs from the author's feed and batch-fetch previews
-	parentURIsSet := map[string]struct{}{}
-	if authorFeed != nil && authorFeed.Feed != nil {
-		for _, fv := range authorFeed.Feed {
-			if fv == nil || fv.Post == nil {
-				continue
-			}
-			if uri := extractReplyParentURI(fv.Post); uri != "" {
-				parentURIsSet[uri] = struct{}{}
-			}
-			chain := GetReplyChainInfos(fv.Post)
-			for _, pi := range chain {
-				if pi.Uri != "" {
-					parentURIsSet[pi.Uri] = struct{}{}
-				}
-			}
-		}
-	}
-
-	var parentURIs []string
-	for u := range parentURIsSet {
-		parentURIs = append(parentURIs, u)
-	}
-
-	parentPreviews := map[string]ParentInfo{}
-	if len(parentURIs) > 0 {
-		const batchSize = 25
-		for i := 0; i < len(parentURIs); i += batchSize {
-			end := i + batchSize
-			if end > len(parentURIs) {
-				end = len(parentURIs)
-			}
-			batch := parentURIs[i:end]
-			postsMap, err := fetchPostsBatch(r.Context(), c, batch)
-			if err != nil {
-				log.Printf("DEBUG: handleProfile - fetchPostsBatch error: %v", err)
-				continue
-			}
-			for uri, pv := range postsMap {
-				if pv == nil {
-					continue
-				}
-				pi := ParentInfo{Uri: uri}
-				if pv.Author != nil {
-					if pv.Author.DisplayName != nil && *pv.Author.DisplayName != "" {
-						pi.AuthorName = *pv.Author.DisplayName
-					} else if pv.Author.Handle != "" {
-						pi.AuthorName = pv.Author.Handle
-					}
-					if pv.Author.Handle != "" {
-						pi.AuthorHandle = pv.Author.Handle
-					}
-					if pv.Author.Avatar != nil {
-						pi.Avatar = *pv.Author.Avatar
-					}
-				}
-				pi.Text = getPostText(pv.Record)
-				if pv.Uri != "" {
-					pi.PostURL = getPostURL(pv)
-				}
-				pi.IndexedAt = pv.IndexedAt
-				// populate media preview if present
-				if m := GetPostMedia(pv); m != nil {
-					pi.Media = m
-				}
-				// like count if available
-				if pv.LikeCount != nil {
-					pi.LikeCount = int(*pv.LikeCount)
-				}
-				pi.IsFav = getIsFav(pv)
-				parentPreviews[uri] = pi
-			}
-		}
-	}
-
 	data := ProfilePageData{
 		Title:         "Profile - Tuiter 2006",
 		Profile:       profileView,
 		Feed:          authorFeed,
 		Follows:       followsList,
-		Posts:         PostsList{Items: authorFeed.Feed, Cursor: getCursorFromAuthorFeed(authorFeed), ParentPreviews: parentPreviews},
+		Tab:           tab.Name,
+		Pinned:        pinned,
+		Posts:         posts,
 		PostBoxHandle: postBoxHandle,
 		// provide the signed-in profile explicitly
 		SignedIn: myProfile,
@@ -23,6 +23,8 @@ type PostsList struct {
 	// ParentPreviews holds pre-fetched ParentInfo keyed by parent URI. Handlers should populate
 	// this map by collecting all reply-ref URIs and calling fetchPostsBatch once.
 	ParentPreviews map[string]ParentInfo
+	// ViewerDid is the signed-in user's DID, used to show owner-only actions such as delete.
+	ViewerDid string
 }
 
 func getPostText(record *util.LexiconTypeDecoder) string {
@@ -41,12 +43,14 @@ func resolveHandleToDID(ctx context.Context, c *client.APIClient, identifier str
 	if strings.HasPrefix(identifier, "did:") {
 		return identifier, nil
 	}
-	profile, err := bsky.ActorGetProfile(ctx, c, identifier)
-	if err != nil {
-		log.Printf("DEBUG: resolveHandleToDID - error resolving handle %s: %v", identifier, err)
-		return "", err
-	}
-	return profile.Did, nil
+	return handleCache.Get(ctx, strings.ToLower(identifier), func(ctx context.Context) (string, error) {
+		profile, err := bsky.ActorGetProfile(ctx, c, identifier)
+		if err != nil {
+			log.Printf("DEBUG: resolveHandleToDID - error resolving handle %s: %v", identifier, err)
+			return "", err
+		}
+		return profile.Did, nil
+	})
 }
 
 func executeTemplate(w http.ResponseWriter, templateName string, data interface{}) {
@@ -159,15 +163,18 @@ func getClientFromSession(ctx context.Context, r *http.Request) (*client.APIClie
 }
 
 func fetchFollows(ctx context.Context, c *client.APIClient, did string, limit int64) []*bsky.ActorDefs_ProfileView {
-	follows, err := bsky.GraphGetFollows(ctx, c, did, "", limit)
+	follows, err := followsCache.Get(ctx, cacheKey{Viewer: viewerOf(c), Subject: did, Limit: limit}, func(ctx context.Context) ([]*bsky.ActorDefs_ProfileView, error) {
+		out, err := bsky.GraphGetFollows(ctx, c, did, "", limit)
+		if err != nil {
+			return nil, err
+		}
+		return out.Follows, nil
+	})
 	if err != nil {
 		log.Printf("DEBUG: fetchFollows - error fetching follows for %s: %v", did, err)
 		return nil
 	}
-	if follows == nil || follows.Follows == nil {
-		return nil
-	}
-	return follows.Follows
+	return follows
 }
 
 func fetchProfile(ctx context.Context, c *client.APIClient, idOrHandle string) (*bsky.ActorDefs_ProfileViewDetailed, error) {
@@ -181,11 +188,9 @@ func fetchProfile(ctx context.Context, c *client.APIClient, idOrHandle string) (
 		}
 		idOrHandle = resolved
 	}
-	profile, err := bsky.ActorGetProfile(ctx, c, idOrHandle)
-	if err != nil {
-		return nil, err
-	}
-	return profile, nil
+	return profileCache.Get(ctx, cacheKey{Viewer: viewerOf(c), Subject: idOrHandle}, func(ctx context.Context) (*bsky.ActorDefs_ProfileViewDetailed, error) {
+		return bsky.ActorGetProfile(ctx, c, idOrHandle)
+	})
 }
 
 func getCursorFromTimeline(t *bsky.FeedGetTimeline_Output) string {
@@ -198,6 +203,31 @@ func getCursorFromTimeline(t *bsky.FeedGetTimeline_Output) string {
 	return ""
 }
 
+// ProfileTab is a tab of the profile page and the getAuthorFeed filter behind it.
+type ProfileTab struct {
+	Name   string
+	Label  string
+	Filter string
+}
+
+// profileTabs are the profile page tabs, in display order. The first one is the default.
+var profileTabs = []ProfileTab{
+	{Name: "updates", Label: "Updates", Filter: "posts_no_replies"},
+	{Name: "replies", Label: "With replies", Filter: "posts_with_replies"},
+	{Name: "media", Label: "Media", Filter: "posts_with_media"},
+	{Name: "threads", Label: "Threads", Filter: "posts_and_author_threads"},
+}
+
+// profileTab returns the profile tab named name, defaulting to the first tab.
+func profileTab(name string) ProfileTab {
+	for _, t := range profileTabs {
+		if t.Name == name {
+			return t
+		}
+	}
+	return profileTabs[0]
+}
+
 func getCursorFromAuthorFeed(f *bsky.FeedGetAuthorFeed_Output) string {
 	if f == nil {
 		return ""
@@ -483,14 +513,88 @@ func MakeElementID(uri string) string {
 }
 
 // ThreadNodeWrapper bundles a ThreadViewPost with the ViewedURI so templates can access both typed values safely.
+// Unavailable is set when the post is by a muted account and should render as a placeholder.
 type ThreadNodeWrapper struct {
 	Post      *bsky.FeedDefs_ThreadViewPost
 	ViewedURI string
+	// ViewerDid is the signed-in user, whose own posts get a delete link ("" when signed out).
+	ViewerDid   string
+	Unavailable *UnavailablePost
+}
+
+// wrapThread is a template helper that wraps a ThreadViewPost with the current viewed URI and
+// the signed-in DID.
+func wrapThread(n *bsky.FeedDefs_ThreadViewPost, viewedURI, viewerDid string) ThreadNodeWrapper {
+	w := ThreadNodeWrapper{Post: n, ViewedURI: viewedURI, ViewerDid: viewerDid}
+	if n != nil && n.Post != nil && n.Post.Uri != viewedURI && isMutedAuthor(n.Post.Author) {
+		w.Unavailable = &UnavailablePost{Kind: unavailableMuted, Uri: n.Post.Uri, PostURL: getPostURL(n.Post)}
+	}
+	return w
+}
+
+// Kinds of UnavailablePost.
+const (
+	unavailableDeleted = "deleted"
+	unavailableBlocked = "blocked"
+	unavailableMuted   = "muted"
+)
+
+// UnavailablePost is the placeholder shown in a thread where a post was deleted, is hidden by a
+// block, or is by an account the viewer muted, so the thread keeps its shape.
+type UnavailablePost struct {
+	Kind string
+	Uri  string
+	// PostURL links to the post when it can still be opened (muted posts only).
+	PostURL string
+}
+
+// Message is the placeholder text for the post.
+func (u *UnavailablePost) Message() string {
+	switch u.Kind {
+	case unavailableDeleted:
+		return "This tweet was deleted"
+	case unavailableBlocked:
+		return "Blocked post"
+	default:
+		return "Post by someone you muted"
+	}
+}
+
+// ThreadItem is one post of a conversation chain: either the post, or a placeholder for it.
+type ThreadItem struct {
+	Post        *bsky.FeedDefs_PostView
+	Unavailable *UnavailablePost
+}
+
+// threadItemFromPost wraps a post for a conversation chain, hiding it behind a placeholder
+// when its author is muted.
+func threadItemFromPost(pv *bsky.FeedDefs_PostView) ThreadItem {
+	if isMutedAuthor(pv.Author) {
+		return ThreadItem{Unavailable: &UnavailablePost{Kind: unavailableMuted, Uri: pv.Uri, PostURL: getPostURL(pv)}}
+	}
+	return ThreadItem{Post: pv}
 }
 
-// wrapThread is a template helper that wraps a ThreadViewPost with the current viewed URI.
-func wrapThread(n *bsky.FeedDefs_ThreadViewPost, viewedURI string) ThreadNodeWrapper {
-	return ThreadNodeWrapper{Post: n, ViewedURI: viewedURI}
+// isMutedAuthor reports whether the viewer muted the author, directly or through a mute list.
+func isMutedAuthor(author *bsky.ActorDefs_ProfileViewBasic) bool {
+	if author == nil || author.Viewer == nil {
+		return false
+	}
+	return (author.Viewer.Muted != nil && *author.Viewer.Muted) || author.Viewer.MutedByList != nil
+}
+
+// replyPlaceholder is a template helper returning the placeholder for a deleted or blocked
+// reply in a thread, or nil when the reply is a regular post.
+func replyPlaceholder(r *bsky.FeedDefs_ThreadViewPost_Replies_Elem) *UnavailablePost {
+	switch {
+	case r == nil:
+		return nil
+	case r.FeedDefs_NotFoundPost != nil:
+		return &UnavailablePost{Kind: unavailableDeleted, Uri: r.FeedDefs_NotFoundPost.Uri}
+	case r.FeedDefs_BlockedPost != nil:
+		return &UnavailablePost{Kind: unavailableBlocked, Uri: r.FeedDefs_BlockedPost.Uri}
+	}
+	return nil
 }
 
 // HasItems is a tiny helper to ask if a PostsList has items; keeps templates readable.
@@ -671,6 +775,8 @@ type ParentInfo struct {
 	Media        *MediaVM
 	// whether the signed-in viewer has liked this post (from PostView.Viewer.Like)
 	IsFav bool
+	// whether the signed-in viewer has reposted this post (from PostView.Viewer.Repost)
+	IsRt bool
 	// like count for the parent post (populated by handlers from PostView.LikeCount)
 	LikeCount   int
 	ReplyCount  int
@@ -838,3 +944,95 @@ func getIsFav(post *bsky.FeedDefs_PostView) bool {
 	}
 	return len(*post.Viewer.Like) > 0
 }
+
+func getIsRt(post *bsky.FeedDefs_PostView) bool {
+	if post == nil || post.Viewer == nil || post.Viewer.Repost == nil {
+		return false
+	}
+	return len(*post.Viewer.Repost) > 0
+}
+
+// getRepostURI returns the viewer's repost record of post, if the appview knows of one.
+func getRepostURI(post *bsky.FeedDefs_PostView) string {
+	if !getIsRt(post) {
+		return ""
+	}
+	return *post.Viewer.Repost
+}
+
+// getRepostCount exposes RepostCount safely to templates.
+func getRepostCount(post *bsky.FeedDefs_PostView) int {
+	if post == nil || post.RepostCount == nil {
+		return 0
+	}
+	return int(*post.RepostCount)
+}
+
+// getReplyCount exposes ReplyCount safely to templates.
+func getReplyCount(post *bsky.FeedDefs_PostView) int {
+	if post == nil || post.ReplyCount == nil {
+		return 0
+	}
+	return int(*post.ReplyCount)
+}
+
+// followPlaces are the spots on a page that render a follow button for the same profile.
+// "row" is the button in profile lists such as people search.
+var followPlaces = []string{"header", "sidebar", "row"}
+
+// FollowButtonVM is the view model of the follow/unfollow button.
+type FollowButtonVM struct {
+	Did        string
+	Following  bool
+	FollowedBy bool
+	// FollowURI is the viewer's follow record, sent back on unfollow so it doesn't depend on
+	// the appview having indexed it yet.
+	FollowURI string
+	// Place tells apart copies of the button on one page (see followPlaces), so the copy that
+	// wasn't clicked can be updated out-of-band.
+	Place string
+	OOB   bool
+}
+
+// ElementID is the DOM id of the button, unique per profile and place.
+func (v FollowButtonVM) ElementID() string {
+	return "follow-" + v.Place + "-" + didElementSuffix(v.Did)
+}
+
+// followButton builds the follow button for a profile from its viewer state. Returns nil for
+// profiles without viewer state (e.g. when signed out).
+func followButton(actor interface{}, place string) *FollowButtonVM {
+	var did string
+	var viewer *bsky.ActorDefs_ViewerState
+	switch a := actor.(type) {
+	case *bsky.ActorDefs_ProfileViewDetailed:
+		if a != nil {
+			did, viewer = a.Did, a.Viewer
+		}
+	case *bsky.ActorDefs_ProfileView:
+		if a != nil {
+			did, viewer = a.Did, a.Viewer
+		}
+	}
+	if did == "" || viewer == nil {
+		return nil
+	}
+	vm := &FollowButtonVM{
+		Did:        did,
+		FollowedBy: viewer.FollowedBy != nil && *viewer.FollowedBy != "",
+		Place:      place,
+	}
+	if viewer.Following != nil && *viewer.Following != "" {
+		vm.Following, vm.FollowURI = true, *viewer.Following
+	}
+	return vm
+}
+
+// followersCountID is the DOM id of a profile's follower count, updated after follow/unfollow.
+func followersCountID(did string) string {
+	return "followers-count-" + didElementSuffix(did)
+}
+
+func didElementSuffix(did string) string {
+	return strings.NewReplacer(":", "-", ".", "-", "%", "-").Replace(did)
+}
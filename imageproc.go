@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// Images are never uploaded as received: decoding and re-encoding them drops EXIF/XMP metadata
+// (including GPS location), EXIF orientation is applied to the pixels, and images are scaled
+// down and recompressed until they fit the PDS limits.
+
+const (
+	// maxImageDimension is the longest side Bluesky clients upload; larger images are scaled down.
+	maxImageDimension = 2000
+	// maxImagePixels guards against decompression bombs before an image is fully decoded. The
+	// decoded image, its toRGBA copy and the applyOrientation copy can each take 4 bytes per
+	// pixel, so one upload peaks around 300MB at this cap, which still accepts 24MP camera photos.
+	maxImagePixels = 24000000
+)
+
+// jpegQualities are tried in order until the encoded JPEG fits under maxImageBytes.
+var jpegQualities = []int{90, 80, 70, 60}
+
+// processedImage is the re-encoded result of processImage.
+type processedImage struct {
+	Data     []byte
+	MimeType string
+	Width    int
+	Height   int
+}
+
+// processImage strips metadata from an uploaded image, applies its EXIF orientation, fits it
+// within maxImageDimension and re-encodes it under maxImageBytes. PNGs stay PNG when they fit,
+// opaque images fall back to JPEG, and animated GIFs are kept only if they already fit.
+func processImage(data []byte, mimeType string) (processedImage, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return processedImage{}, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return processedImage{}, fmt.Errorf("image dimensions %dx%d not supported", cfg.Width, cfg.Height)
+	}
+
+	if mimeType == "image/gif" {
+		if out, ok := reencodeAnimatedGIF(data, cfg); ok {
+			return out, nil
+		}
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return processedImage{}, err
+	}
+	orientation := 1
+	if mimeType == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	img := applyOrientation(toRGBA(src), orientation)
+
+	w, h := fitDimensions(img.Bounds().Dx(), img.Bounds().Dy(), maxImageDimension)
+	if w != img.Bounds().Dx() || h != img.Bounds().Dy() {
+		img = downscale(img, w, h)
+	}
+
+	// shrink by a quarter each round until some encoding fits
+	for round := 0; round < 8; round++ {
+		if out, mime, ok := encodeUnderLimit(img, mimeType != "image/jpeg"); ok {
+			b := img.Bounds()
+			return processedImage{Data: out, MimeType: mime, Width: b.Dx(), Height: b.Dy()}, nil
+		}
+		b := img.Bounds()
+		if b.Dx() < 4 || b.Dy() < 4 {
+			break
+		}
+		img = downscale(img, b.Dx()*3/4, b.Dy()*3/4)
+	}
+	return processedImage{}, fmt.Errorf("unable to compress image under %d KB", maxImageBytes/1000)
+}
+
+// reencodeAnimatedGIF re-encodes a multi-frame GIF frame by frame, which drops comment and
+// application metadata blocks. It reports false when the GIF is a still image or doesn't fit
+// the limits, in which case it goes through the regular pipeline as a single frame.
+func reencodeAnimatedGIF(data []byte, cfg image.Config) (processedImage, bool) {
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension {
+		return processedImage{}, false
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(g.Image) < 2 {
+		return processedImage{}, false
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil || buf.Len() > maxImageBytes {
+		return processedImage{}, false
+	}
+	return processedImage{Data: buf.Bytes(), MimeType: "image/gif", Width: cfg.Width, Height: cfg.Height}, true
+}
+
+// encodeUnderLimit encodes img as PNG (when preferPNG) or JPEG at decreasing qualities and
+// returns the first encoding that fits under maxImageBytes. Images with transparency are never
+// turned into JPEG.
+func encodeUnderLimit(img *image.RGBA, preferPNG bool) ([]byte, string, bool) {
+	var buf bytes.Buffer
+	if preferPNG {
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := enc.Encode(&buf, img); err == nil && buf.Len() <= maxImageBytes {
+			return buf.Bytes(), "image/png", true
+		}
+		if !img.Opaque() {
+			return nil, "", false
+		}
+	}
+	for _, q := range jpegQualities {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q}); err != nil {
+			return nil, "", false
+		}
+		if buf.Len() <= maxImageBytes {
+			return buf.Bytes(), "image/jpeg", true
+		}
+	}
+	return nil, "", false
+}
+
+// fitDimensions scales w x h down (never up) so that neither side exceeds limit, keeping the
+// aspect ratio.
+func fitDimensions(w, h, limit int) (int, int) {
+	if w <= limit && h <= limit {
+		return w, h
+	}
+	if w >= h {
+		return limit, max(1, h*limit/w)
+	}
+	return max(1, w*limit/h), limit
+}
+
+// toRGBA converts any decoded image into a zero-origin RGBA image.
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// downscale resizes src to w x h with a box filter, averaging every source pixel that falls
+// into each destination pixel. Only meant for shrinking.
+func downscale(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for dy := 0; dy < h; dy++ {
+		y0, y1 := dy*sh/h, (dy+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for dx := 0; dx < w; dx++ {
+			x0, x1 := dx*sw/w, (dx+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint32
+			for y := y0; y < y1; y++ {
+				off := src.PixOffset(x0, y)
+				for x := x0; x < x1; x++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					b += uint32(src.Pix[off+2])
+					a += uint32(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// applyOrientation returns src transformed so that it displays upright for the given EXIF
+// orientation (1-8). Orientations 5-8 swap width and height.
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var nx, ny int
+			switch orientation {
+			case 2: // mirrored horizontally
+				nx, ny = w-1-x, y
+			case 3: // rotated 180
+				nx, ny = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				nx, ny = x, h-1-y
+			case 5: // transposed
+				nx, ny = y, x
+			case 6: // needs 90 clockwise
+				nx, ny = h-1-y, x
+			case 7: // transversed
+				nx, ny = h-1-y, w-1-x
+			case 8: // needs 90 counter-clockwise
+				nx, ny = y, w-1-x
+			}
+			dst.SetRGBA(nx, ny, src.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation tag of a JPEG, or 1 when there is none.
+// It walks the marker segments up to the start of scan looking for the APP1 Exif block.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // fill byte
+			i++
+			continue
+		case marker == 0xDA || marker == 0xD9: // start of scan, end of image
+			return 1
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // no length
+			i += 2
+			continue
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return exifOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation reads the Orientation tag (0x0112) from IFD0 of a TIFF-structured EXIF block.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(bo.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(bo.Uint16(tiff[ifd:]))
+	for k := 0; k < entries; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(tiff) {
+			return 1
+		}
+		if bo.Uint16(tiff[e:]) == 0x0112 {
+			if o := int(bo.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
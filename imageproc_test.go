@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{R: 255, A: 255}
+	green = color.RGBA{G: 255, A: 255}
+	blue  = color.RGBA{B: 255, A: 255}
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// quadrantImage is a w x h image whose quadrants are red, green (top) and blue, white (bottom).
+func quadrantImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := red
+			switch {
+			case x >= w/2 && y < h/2:
+				c = green
+			case x < w/2 && y >= h/2:
+				c = blue
+			case x >= w/2 && y >= h/2:
+				c = white
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func noiseImage(w, h int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	rng.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// gpsMarker stands in for location metadata; it must never survive processImage.
+const gpsMarker = "GPS 48.8584N 2.2945E"
+
+// withExif inserts an APP1 Exif segment right after the JPEG SOI marker. IFD0 holds the
+// orientation and a GPSInfo pointer to an IFD whose only entry is an ASCII gpsMarker.
+func withExif(jpg []byte, orientation uint16) []byte {
+	le := binary.LittleEndian
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	entry := func(tag, typ uint16, count, value uint32) []byte {
+		e := make([]byte, 12)
+		le.PutUint16(e, tag)
+		le.PutUint16(e[2:], typ)
+		le.PutUint32(e[4:], count)
+		le.PutUint32(e[8:], value)
+		return e
+	}
+	// IFD0 at 8: 2 entries (2 + 24 + 4 bytes), GPS IFD at 38: 1 entry (2 + 12 + 4), string at 56
+	tiff = binary.LittleEndian.AppendUint16(tiff, 2)
+	tiff = append(tiff, entry(0x0112, 3, 1, uint32(orientation))...)
+	tiff = append(tiff, entry(0x8825, 4, 1, 38)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = append(tiff, entry(0x0002, 2, uint32(len(gpsMarker)+1), 56)...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	tiff = append(tiff, gpsMarker+"\x00"...)
+
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(seg)+2))
+	app1 = append(app1, seg...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, app1...)
+	return append(out, jpg[2:]...)
+}
+
+// withPNGText inserts a tEXt chunk after the PNG IHDR chunk.
+func withPNGText(p []byte, key, value string) []byte {
+	data := append([]byte(key+"\x00"), value...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	body := append([]byte("tEXt"), data...)
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	out := append([]byte{}, p[:ihdrEnd]...)
+	out = append(out, chunk...)
+	return append(out, p[ihdrEnd:]...)
+}
+
+func near(a, b color.RGBA) bool {
+	d := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+	return d(a.R, b.R) < 48 && d(a.G, b.G) < 48 && d(a.B, b.B) < 48
+}
+
+func decodeRGBA(t *testing.T, data []byte) *image.RGBA {
+	t.Helper()
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decoding processed image: %v", err)
+	}
+	return toRGBA(img)
+}
+
+func TestProcessImageOrientation(t *testing.T) {
+	// the 40x20 source is red, green over blue, white; each case lists the displayed
+	// quadrants top-left, top-right, bottom-left, bottom-right
+	tests := []struct {
+		orientation uint16
+		w, h        int
+		quadrants   [4]color.RGBA
+	}{
+		{1, 40, 20, [4]color.RGBA{red, green, blue, white}},
+		{2, 40, 20, [4]color.RGBA{green, red, white, blue}},
+		{3, 40, 20, [4]color.RGBA{white, blue, green, red}},
+		{4, 40, 20, [4]color.RGBA{blue, white, red, green}},
+		{5, 20, 40, [4]color.RGBA{red, blue, green, white}},
+		{6, 20, 40, [4]color.RGBA{blue, red, white, green}},
+		{7, 20, 40, [4]color.RGBA{white, green, blue, red}},
+		{8, 20, 40, [4]color.RGBA{green, white, red, blue}},
+	}
+	src := encodeJPEG(t, quadrantImage(40, 20))
+	for _, tt := range tests {
+		data := withExif(src, tt.orientation)
+		if got := jpegOrientation(data); got != int(tt.orientation) {
+			t.Fatalf("jpegOrientation = %d, want %d", got, tt.orientation)
+		}
+		out, err := processImage(data, "image/jpeg")
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+		if out.Width != tt.w || out.Height != tt.h {
+			t.Errorf("orientation %d: size %dx%d, want %dx%d", tt.orientation, out.Width, out.Height, tt.w, tt.h)
+			continue
+		}
+		img := decodeRGBA(t, out.Data)
+		points := [4][2]int{{tt.w / 4, tt.h / 4}, {tt.w * 3 / 4, tt.h / 4}, {tt.w / 4, tt.h * 3 / 4}, {tt.w * 3 / 4, tt.h * 3 / 4}}
+		for i, p := range points {
+			if got := img.RGBAAt(p[0], p[1]); !near(got, tt.quadrants[i]) {
+				t.Errorf("orientation %d: quadrant %d is %v, want %v", tt.orientation, i, got, tt.quadrants[i])
+			}
+		}
+		if jpegOrientation(out.Data) != 1 {
+			t.Errorf("orientation %d: output still carries an orientation", tt.orientation)
+		}
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     func(t *testing.T) []byte
+		mimeType string
+		wantMime string
+		wantW    int
+		wantH    int
+	}{
+		{
+			name:     "jpeg with exif and gps",
+			data:     func(t *testing.T) []byte { return withExif(encodeJPEG(t, quadrantImage(64, 48)), 1) },
+			mimeType: "image/jpeg",
+			wantMime: "image/jpeg",
+			wantW:    64, wantH: 48,
+		},
+		{
+			name: "png with text metadata",
+			data: func(t *testing.T) []byte {
+				return withPNGText(encodePNG(t, quadrantImage(64, 48)), "Location", gpsMarker)
+			},
+			mimeType: "image/png",
+			wantMime: "image/png",
+			wantW:    64, wantH: 48,
+		},
+		{
+			name:     "oversized jpeg is downscaled",
+			data:     func(t *testing.T) []byte { return encodeJPEG(t, quadrantImage(3000, 1500)) },
+			mimeType: "image/jpeg",
+			wantMime: "image/jpeg",
+			wantW:    2000, wantH: 1000,
+		},
+		{
+			name:     "oversized portrait png is downscaled",
+			data:     func(t *testing.T) []byte { return encodePNG(t, quadrantImage(1200, 2400)) },
+			mimeType: "image/png",
+			wantMime: "image/png",
+			wantW:    1000, wantH: 2000,
+		},
+		{
+			name:     "incompressible png becomes a jpeg under the limit",
+			data:     func(t *testing.T) []byte { return encodePNG(t, noiseImage(1600, 1600)) },
+			mimeType: "image/png",
+			wantMime: "image/jpeg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := processImage(tt.data(t), tt.mimeType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.MimeType != tt.wantMime {
+				t.Errorf("MimeType = %s, want %s", out.MimeType, tt.wantMime)
+			}
+			if tt.wantW > 0 && (out.Width != tt.wantW || out.Height != tt.wantH) {
+				t.Errorf("size %dx%d, want %dx%d", out.Width, out.Height, tt.wantW, tt.wantH)
+			}
+			if len(out.Data) > maxImageBytes {
+				t.Errorf("output is %d bytes, over the %d limit", len(out.Data), maxImageBytes)
+			}
+			if bytes.Contains(out.Data, []byte(gpsMarker)) || bytes.Contains(out.Data, []byte("Exif\x00")) || bytes.Contains(out.Data, []byte("tEXt")) {
+				t.Error("metadata survived re-encoding")
+			}
+			b := decodeRGBA(t, out.Data).Bounds()
+			if b.Dx() != out.Width || b.Dy() != out.Height {
+				t.Errorf("reported %dx%d but encoded %dx%d", out.Width, out.Height, b.Dx(), b.Dy())
+			}
+		})
+	}
+}
+
+func TestProcessImageRejectsHugeDimensions(t *testing.T) {
+	// only the header is read before the pixel cap applies, so a real image isn't needed
+	p := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	binary.BigEndian.PutUint32(p[16:], 10000)
+	binary.BigEndian.PutUint32(p[20:], 10000)
+	if _, err := processImage(p, "image/png"); err == nil {
+		t.Fatal("expected a 100MP image to be rejected")
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	src := quadrantImage(100, 50)
+	dst := downscale(src, 10, 5)
+	if b := dst.Bounds(); b.Dx() != 10 || b.Dy() != 5 {
+		t.Fatalf("size %v", b)
+	}
+	if got := dst.RGBAAt(1, 1); got != red {
+		t.Errorf("top-left = %v, want red", got)
+	}
+	if got := dst.RGBAAt(8, 3); got != white {
+		t.Errorf("bottom-right = %v, want white", got)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+const (
+	// maxPostImages is the number of images app.bsky.embed.images accepts per post.
+	maxPostImages = 4
+	// maxImageBytes is the PDS blob size limit for images embedded in posts.
+	maxImageBytes = 1000000
+	// maxImageInputBytes bounds a single file as sent by the browser, before processImage
+	// shrinks it under maxImageBytes.
+	maxImageInputBytes = 20 << 20
+	// maxUploadBytes bounds the whole multipart request body of the post box.
+	maxUploadBytes = maxPostImages*maxImageInputBytes + 1<<20
+	// maxUploadMemory is how much of the multipart body is kept in memory; the rest spills to disk.
+	maxUploadMemory = 32 << 20
+)
+
+// allowedImageTypes lists the sniffed MIME types accepted for post images. These are the
+// formats the standard library can decode and re-encode in processImage.
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// imageUpload is an image read from the post box, ready to be uploaded as a blob.
+type imageUpload struct {
+	Data     []byte
+	MimeType string
+	Alt      string
+	Width    int
+	Height   int
+}
+
+// imageSlots returns the indexes of the image inputs rendered in the post box.
+func imageSlots() []int {
+	slots := make([]int, maxPostImages)
+	for i := range slots {
+		slots[i] = i
+	}
+	return slots
+}
+
+// parsePostForm parses the post box form, which is multipart when images are attached.
+// Plain url-encoded submissions are left to r.FormValue.
+func parsePostForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// readImageUploads reads the image-N file inputs (and their alt-N text fields) from a parsed
+// multipart form, validating type and size and running each image through processImage so
+// that only metadata-free images within the PDS limits get uploaded.
+func readImageUploads(r *http.Request) ([]imageUpload, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	var uploads []imageUpload
+	for _, i := range imageSlots() {
+		key := strconv.Itoa(i)
+		headers := r.MultipartForm.File["image-"+key]
+		if len(headers) == 0 || headers[0].Size == 0 {
+			continue
+		}
+		fh := headers[0]
+		if fh.Size > maxImageInputBytes {
+			return nil, fmt.Errorf("%s is too large (max %d MB)", fh.Filename, maxImageInputBytes>>20)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxImageInputBytes+1))
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxImageInputBytes {
+			return nil, fmt.Errorf("%s is too large (max %d MB)", fh.Filename, maxImageInputBytes>>20)
+		}
+
+		// trust the bytes, not the client-declared Content-Type
+		mimeType := http.DetectContentType(data)
+		if !allowedImageTypes[mimeType] {
+			return nil, fmt.Errorf("%s: unsupported image type %s", fh.Filename, mimeType)
+		}
+		img, err := processImage(data, mimeType)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to process image: %w", fh.Filename, err)
+		}
+
+		uploads = append(uploads, imageUpload{
+			Data:     img.Data,
+			MimeType: img.MimeType,
+			Alt:      strings.TrimSpace(r.FormValue("alt-" + key)),
+			Width:    img.Width,
+			Height:   img.Height,
+		})
+	}
+	return uploads, nil
+}
+
+// uploadImages uploads each image through com.atproto.repo.uploadBlob with its re-encoded
+// MIME type and returns the app.bsky.embed.images embed referencing the blobs. Returns nil
+// when there are no images.
+func uploadImages(ctx context.Context, c *client.APIClient, uploads []imageUpload) (*bsky.EmbedImages, error) {
+	if len(uploads) == 0 {
+		return nil, nil
+	}
+	embed := &bsky.EmbedImages{}
+	for _, up := range uploads {
+		blob, err := uploadBlobWithType(ctx, c, up.MimeType, bytes.NewReader(up.Data))
+		if err != nil {
+			return nil, err
+		}
+		img := &bsky.EmbedImages_Image{Alt: up.Alt, Image: blob}
+		if up.Width > 0 && up.Height > 0 {
+			img.AspectRatio = &bsky.EmbedDefs_AspectRatio{Width: int64(up.Width), Height: int64(up.Height)}
+		}
+		embed.Images = append(embed.Images, img)
+	}
+	return embed, nil
+}
+
+// imagesFromRequest parses the post form and uploads any attached images. On error, the
+// returned status is the HTTP status the handler should respond with.
+func imagesFromRequest(w http.ResponseWriter, r *http.Request, c *client.APIClient) (*bsky.EmbedImages, int, error) {
+	if err := parsePostForm(w, r); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	uploads, err := readImageUploads(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	images, err := uploadImages(r.Context(), c, uploads)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return images, 0, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+)
+
+// Lists are app.bsky.graph.list records; each member is an app.bsky.graph.listitem record in
+// the list owner's repo. Tuiter only manages curate lists (Twitter-style lists you can read
+// as a timeline), not moderation lists.
+
+const (
+	listCollection     = "app.bsky.graph.list"
+	listItemCollection = "app.bsky.graph.listitem"
+	curateListPurpose  = "app.bsky.graph.defs#curatelist"
+
+	// maxListNameLength and maxListDescriptionLength are the lexicon's grapheme limits.
+	maxListNameLength        = 64
+	maxListDescriptionLength = 300
+	// maxListItemPages bounds how many pages of 100 listitem records are scanned for membership;
+	// past it listItemRecords fails rather than report members as missing.
+	maxListItemPages = 100
+	// maxApplyWrites is how many operations the PDS accepts in one applyWrites call.
+	maxApplyWrites = 200
+)
+
+// listURI returns the at:// URI of a list record.
+func listURI(did, rkey string) string {
+	return "at://" + did + "/" + listCollection + "/" + rkey
+}
+
+// listPageURL returns the local /list/{did}/{rkey} page of a list URI, or "" for anything else.
+func listPageURL(uri string) string {
+	u, err := syntax.ParseATURI(uri)
+	if err != nil || u.Collection().String() != listCollection || u.RecordKey() == "" {
+		return ""
+	}
+	return "/list/" + u.Authority().String() + "/" + u.RecordKey().String()
+}
+
+// isCurateList reports whether a list is a curate list.
+func isCurateList(l *bsky.GraphDefs_ListView) bool {
+	return l != nil && l.Purpose != nil && *l.Purpose == curateListPurpose
+}
+
+// fetchCurateLists returns the curate lists created by actor.
+func fetchCurateLists(ctx context.Context, c *client.APIClient, actor string) ([]*bsky.GraphDefs_ListView, error) {
+	out, err := bsky.GraphGetLists(ctx, c, actor, "", 100)
+	if err != nil {
+		return nil, err
+	}
+	var lists []*bsky.GraphDefs_ListView
+	for _, l := range out.Lists {
+		if isCurateList(l) {
+			lists = append(lists, l)
+		}
+	}
+	return lists, nil
+}
+
+// listItemRecords scans all the listitem records in repo, across every list, and returns
+// those about subject keyed by listitem URI. Listitem records can only be listed per
+// collection, so the whole collection is paged through; it errors past maxListItemPages
+// rather than return a partial set.
+func listItemRecords(ctx context.Context, c *client.APIClient, repo, subject string) (map[string]*bsky.GraphListitem, error) {
+	items := map[string]*bsky.GraphListitem{}
+	cursor := ""
+	for page := 0; ; page++ {
+		if page == maxListItemPages {
+			return nil, fmt.Errorf("more than %d pages of list items", maxListItemPages)
+		}
+		out, err := atproto.RepoListRecords(ctx, c, listItemCollection, cursor, 100, repo, false)
+		if err != nil {
+			return nil, fmt.Errorf("listRecords error: %w", err)
+		}
+		for _, rec := range out.Records {
+			if rec == nil || rec.Value == nil {
+				continue
+			}
+			if item, ok := rec.Value.Val.(*bsky.GraphListitem); ok && item.Subject == subject {
+				items[rec.Uri] = item
+			}
+		}
+		if out.Cursor == nil || *out.Cursor == "" {
+			return items, nil
+		}
+		cursor = *out.Cursor
+	}
+}
+
+// listItemURIs returns the URIs of every listitem record in list, paging through getList
+// until its cursor runs out.
+func listItemURIs(ctx context.Context, c *client.APIClient, list string) ([]string, error) {
+	var uris []string
+	cursor := ""
+	for {
+		out, err := bsky.GraphGetList(ctx, c, cursor, 100, list)
+		if err != nil {
+			return nil, fmt.Errorf("getList error: %w", err)
+		}
+		for _, item := range out.Items {
+			if item != nil && item.Uri != "" {
+				uris = append(uris, item.Uri)
+			}
+		}
+		if out.Cursor == nil || *out.Cursor == "" || len(out.Items) == 0 {
+			return uris, nil
+		}
+		cursor = *out.Cursor
+	}
+}
+
+// deleteList deletes the list record listRkey in repo together with its listitem records
+// through applyWrites. Lists that fit in one call are deleted atomically; bigger ones are
+// deleted in chunks with the list record in the last chunk, so a failure leaves the list in
+// place rather than orphaned items.
+func deleteList(ctx context.Context, c *client.APIClient, repo, listRkey string, itemURIs []string) error {
+	writes := make([]*atproto.RepoApplyWrites_Input_Writes_Elem, 0, len(itemURIs)+1)
+	for _, itemURI := range itemURIs {
+		aturi, err := syntax.ParseATURI(itemURI)
+		if err != nil || aturi.RecordKey() == "" {
+			return fmt.Errorf("invalid list item %q", itemURI)
+		}
+		writes = append(writes, &atproto.RepoApplyWrites_Input_Writes_Elem{RepoApplyWrites_Delete: &atproto.RepoApplyWrites_Delete{
+			Collection: listItemCollection,
+			Rkey:       aturi.RecordKey().String(),
+		}})
+	}
+	writes = append(writes, &atproto.RepoApplyWrites_Input_Writes_Elem{RepoApplyWrites_Delete: &atproto.RepoApplyWrites_Delete{
+		Collection: listCollection,
+		Rkey:       listRkey,
+	}})
+	for start := 0; start < len(writes); start += maxApplyWrites {
+		end := min(start+maxApplyWrites, len(writes))
+		if _, err := atproto.RepoApplyWrites(ctx, c, &atproto.RepoApplyWrites_Input{Repo: repo, Writes: writes[start:end]}); err != nil {
+			return fmt.Errorf("applyWrites error: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListMembershipVM is one row of the "lists" picker on a profile: whether the profile is in
+// one of the signed-in user's lists.
+type ListMembershipVM struct {
+	ListURI string
+	Name    string
+	Did     string
+	// ItemURI is the listitem record adding Did to the list, empty when not a member.
+	ItemURI string
+}
+
+// Member reports whether the profile is in the list.
+func (v ListMembershipVM) Member() bool { return v.ItemURI != "" }
+
+// listMemberships builds the picker rows for subject across the given lists.
+func listMemberships(lists []*bsky.GraphDefs_ListView, items map[string]*bsky.GraphListitem, subject string) []ListMembershipVM {
+	itemByList := map[string]string{}
+	for uri, item := range items {
+		if item.Subject == subject {
+			itemByList[item.List] = uri
+		}
+	}
+	rows := make([]ListMembershipVM, 0, len(lists))
+	for _, l := range lists {
+		rows = append(rows, ListMembershipVM{ListURI: l.Uri, Name: l.Name, Did: subject, ItemURI: itemByList[l.Uri]})
+	}
+	return rows
+}
+
+// ListMembersVM is a page of a list's members. Owner enables the remove buttons.
+type ListMembersVM struct {
+	ListURI string
+	Items   []*bsky.GraphDefs_ListItemView
+	Cursor  string
+	Owner   bool
+	// ViewerDid is the signed-in user's DID, as in ActorsList.
+	ViewerDid string
+}
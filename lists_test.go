@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+func TestDeleteList(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     int
+		failCall  int // 1-based applyWrites call that fails, 0 for none
+		wantCalls []int
+		wantErr   bool
+	}{
+		{name: "empty list", items: 0, wantCalls: []int{1}},
+		{name: "one call", items: 3, wantCalls: []int{4}},
+		{name: "chunked", items: 450, wantCalls: []int{200, 200, 51}},
+		{name: "failure keeps the list", items: 450, failCall: 2, wantCalls: []int{200, 200}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls [][]map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/xrpc/com.atproto.repo.applyWrites" {
+					http.NotFound(w, r)
+					return
+				}
+				var in struct {
+					Repo   string
+					Writes []map[string]string
+				}
+				if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Repo != testDID {
+					http.Error(w, `{"error":"InvalidRequest"}`, http.StatusBadRequest)
+					return
+				}
+				calls = append(calls, in.Writes)
+				if len(calls) == tt.failCall {
+					http.Error(w, `{"error":"InternalServerError"}`, http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+
+			var uris []string
+			for i := range tt.items {
+				uris = append(uris, fmt.Sprintf("at://%s/app.bsky.graph.listitem/item%d", testDID, i))
+			}
+			err := deleteList(context.Background(), client.NewAPIClient(srv.URL), testDID, "mylist", uris)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(calls) != len(tt.wantCalls) {
+				t.Fatalf("got %d applyWrites calls, want %d", len(calls), len(tt.wantCalls))
+			}
+			listDeletes := 0
+			for i, writes := range calls {
+				if len(writes) != tt.wantCalls[i] {
+					t.Errorf("call %d has %d writes, want %d", i+1, len(writes), tt.wantCalls[i])
+				}
+				for j, wr := range writes {
+					if wr["$type"] != "com.atproto.repo.applyWrites#delete" {
+						t.Fatalf("write %+v is not a delete", wr)
+					}
+					if wr["collection"] == listCollection {
+						listDeletes++
+						if i != len(calls)-1 || j != len(writes)-1 || wr["rkey"] != "mylist" {
+							t.Errorf("list deleted at call %d write %d, want last", i+1, j+1)
+						}
+					}
+				}
+			}
+			wantListDeletes := 1
+			if tt.wantErr {
+				// the list record was in the chunk that never got sent
+				wantListDeletes = 0
+			}
+			if listDeletes != wantListDeletes {
+				t.Errorf("list record sent %d times, want %d", listDeletes, wantListDeletes)
+			}
+		})
+	}
+
+	t.Run("invalid item uri", func(t *testing.T) {
+		if err := deleteList(context.Background(), client.NewAPIClient("http://127.0.0.1:0"), testDID, "mylist", []string{"not a uri"}); err == nil {
+			t.Fatal("expected an error before any write")
+		}
+	})
+}
+
+func TestListItemURIs(t *testing.T) {
+	list := listURI(testDID, "mylist")
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/app.bsky.graph.getList" || r.URL.Query().Get("list") != list {
+			http.NotFound(w, r)
+			return
+		}
+		calls++
+		start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		out := map[string]any{"list": map[string]any{"uri": list, "cid": "bafyreib2rxk3rh6kzwq", "name": "l", "purpose": curateListPurpose, "creator": map[string]any{"did": testDID, "handle": "me.test"}, "indexedAt": "2025-01-01T00:00:00Z"}}
+		var items []map[string]any
+		for i := start; i < min(start+100, 250); i++ {
+			items = append(items, map[string]any{
+				"uri":     fmt.Sprintf("at://%s/%s/item%d", testDID, listItemCollection, i),
+				"subject": map[string]any{"did": fmt.Sprintf("did:plc:member%d", i), "handle": "member.test"},
+			})
+		}
+		out["items"] = items
+		if start+100 < 250 {
+			out["cursor"] = strconv.Itoa(start + 100)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	}))
+	defer srv.Close()
+
+	uris, err := listItemURIs(context.Background(), client.NewAPIClient(srv.URL), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || len(uris) != 250 {
+		t.Fatalf("got %d URIs in %d calls, want 250 in 3", len(uris), calls)
+	}
+	if want := fmt.Sprintf("at://%s/%s/item249", testDID, listItemCollection); uris[249] != want {
+		t.Errorf("last uri = %s, want %s", uris[249], want)
+	}
+}
+
+func TestListItemRecords(t *testing.T) {
+	subject := "did:plc:member"
+	// records of every list in the repo, the subject's scattered past the first 1000
+	serve := func(total int) (*httptest.Server, *int) {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/xrpc/com.atproto.repo.listRecords" || r.URL.Query().Get("collection") != listItemCollection {
+				http.NotFound(w, r)
+				return
+			}
+			calls++
+			start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+			var records []map[string]any
+			for i := start; i < start+100 && (total < 0 || i < total); i++ {
+				did := fmt.Sprintf("did:plc:other%d", i)
+				if i%700 == 5 {
+					did = subject
+				}
+				records = append(records, map[string]any{
+					"uri": fmt.Sprintf("at://%s/%s/item%d", testDID, listItemCollection, i),
+					"cid": "bafyreib2rxk3rh6kzwq",
+					"value": map[string]any{
+						"$type":     listItemCollection,
+						"subject":   did,
+						"list":      listURI(testDID, fmt.Sprintf("list%d", i%3)),
+						"createdAt": "2025-01-01T00:00:00Z",
+					},
+				})
+			}
+			out := map[string]any{"records": records}
+			if total < 0 || start+100 < total {
+				out["cursor"] = strconv.Itoa(start + 100)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(out)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &calls
+	}
+
+	srv, calls := serve(1500)
+	items, err := listItemRecords(context.Background(), client.NewAPIClient(srv.URL), testDID, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 15 {
+		t.Errorf("got %d listRecords calls, want 15", *calls)
+	}
+	for _, i := range []int{5, 705, 1405} {
+		uri := fmt.Sprintf("at://%s/%s/item%d", testDID, listItemCollection, i)
+		if items[uri] == nil || items[uri].List != listURI(testDID, fmt.Sprintf("list%d", i%3)) {
+			t.Errorf("missing %s in %v", uri, items)
+		}
+	}
+	if len(items) != 3 {
+		t.Errorf("got %d items, want 3", len(items))
+	}
+
+	srv, calls = serve(-1)
+	if _, err := listItemRecords(context.Background(), client.NewAPIClient(srv.URL), testDID, subject); err == nil {
+		t.Error("expected an error instead of a partial scan")
+	}
+	if *calls != maxListItemPages {
+		t.Errorf("got %d listRecords calls, want %d", *calls, maxListItemPages)
+	}
+}
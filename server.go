@@ -26,6 +26,8 @@ var (
 	oauthApp *oauth.ClientApp
 	store    *sessions.CookieStore
 	tpl      *template.Template
+	// settingsStore keeps per-user Tuiter settings such as timeline filters
+	settingsStore *sqliteStore
 )
 
 // Run initializes global state and starts the HTTP server.
@@ -59,10 +61,91 @@ func Run() {
 		log.Fatalf("failed to initialize SQLite store: %v", err)
 	}
 	oauthApp.Store = sqliteStore
+	settingsStore = sqliteStore
 
+	if v := os.Getenv("BSKY_VIDEO_SERVICE"); v != "" {
+		videoService = &bskyVideoService{Host: v}
+	}
+
+	tpl = parseTemplates()
+
+	subStaticFS, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		log.Fatalf("failed to prepare static filesystem: %v", err)
+	}
+
+	http.HandleFunc("/", handleIndex)
+	http.HandleFunc("/signin", handleSignin)
+	http.HandleFunc("/post-status", handlePostStatus)
+	http.HandleFunc("/login", handleLogin)
+	http.HandleFunc("/logout", handleLogout)
+	http.HandleFunc("/oauth-callback", handleOAuthCallback)
+	http.HandleFunc("/oauth-client-metadata.json", handleClientMetadata)
+	http.HandleFunc("/timeline", handleTimeline)
+	http.HandleFunc("/timeline/post", handleTimelinePost)
+	http.HandleFunc("/settings/timeline", handleTimelineFilters)
+	http.HandleFunc("/post/", handlePost)
+	http.HandleFunc("/profile/", handleProfile)
+	http.HandleFunc("/reply", handleReply)
+	http.HandleFunc("/fav", handleFav)
+	http.HandleFunc("/rt", handleRetweet)
+	http.HandleFunc("/quote", handleQuote)
+	http.HandleFunc("/delete", handleDelete)
+	http.HandleFunc("/follow", handleFollow)
+	http.HandleFunc("/compose/video", handleComposeVideo)
+	http.HandleFunc("/compose/video/status", handleComposeVideoStatus)
+	http.HandleFunc("/htmx/timeline", htmxTimelineFeed)
+	http.HandleFunc("/htmx/profile", htmxProfileFeed)
+	http.HandleFunc("/htmx/connections", htmxConnections)
+	http.HandleFunc("/htmx/favorites", htmxFavorites)
+	http.HandleFunc("/search", handleSearch)
+	http.HandleFunc("/htmx/search", htmxSearch)
+	http.HandleFunc("/search/people", handleSearchPeople)
+	http.HandleFunc("/htmx/search/people", htmxSearchPeople)
+	http.HandleFunc("/htmx/typeahead", htmxTypeahead)
+	http.HandleFunc("/tag/", handleTag)
+	http.HandleFunc("/htmx/tag", htmxTag)
+	http.HandleFunc("/feed/", handleFeed)
+	http.HandleFunc("/htmx/feed", htmxFeed)
+	http.HandleFunc("/feeds", handleFeeds)
+	http.HandleFunc("/feeds/save", handleSaveFeed)
+	http.HandleFunc("/lists", handleLists)
+	http.HandleFunc("/lists/create", handleCreateList)
+	http.HandleFunc("/lists/delete", handleDeleteList)
+	http.HandleFunc("/lists/member", handleListMember)
+	http.HandleFunc("/list/", handleList)
+	http.HandleFunc("/htmx/list", htmxListFeed)
+	http.HandleFunc("/htmx/list/members", htmxListMembers)
+	http.HandleFunc("/htmx/lists/memberships", htmxListMemberships)
+	http.HandleFunc("/htmx/sidebar/lists", htmxSidebarLists)
+	http.HandleFunc("/notifications", handleNotifications)
+	http.HandleFunc("/htmx/notifications", htmxNotifications)
+	http.HandleFunc("/htmx/notifications/unread", htmxUnreadCount)
+	http.HandleFunc("/video/", handleVideo)
+	http.HandleFunc("/about", handleAbout)
+	if os.Getenv("TUITER_DEBUG") != "" {
+		http.HandleFunc("/debug/cache", handleCacheStats)
+	}
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(subStaticFS))))
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Println("Listening on http://localhost:" + port)
+	log.Fatal(http.ListenAndServe(":"+port, loggingMiddleware(http.DefaultServeMux)))
+}
+
+// parseTemplates parses the embedded templates with the helpers they use.
+func parseTemplates() *template.Template {
 	funcMap := template.FuncMap{
 		"getPostText":         getPostText,
+		"renderPostText":      renderPostText,
+		"imageSlots":          imageSlots,
 		"getProfileURL":       getProfileURL,
+		"feedPageURL":         feedPageURL,
+		"profileTabs":         func() []ProfileTab { return profileTabs },
+		"listPageURL":         listPageURL,
 		"getPostURL":          getPostURL,
 		"getFollowingCount":   getFollowingCount,
 		"getFollowersCount":   getFollowersCount,
@@ -76,6 +159,7 @@ func Run() {
 		"getMediaForTemplate": GetMediaForTemplate,
 		"makeElementID":       MakeElementID,
 		"wrapThread":          wrapThread,
+		"replyPlaceholder":    replyPlaceholder,
 		// newly added helpers
 		"avatarURL":          AvatarURL,
 		"AvatarURL":          AvatarURL,
@@ -107,40 +191,17 @@ func Run() {
 			}
 			return m
 		},
-		"getIsFav": getIsFav,
+		"getIsFav":     getIsFav,
+		"getIsRt":      getIsRt,
+		"getRepostURI": getRepostURI,
 		// expose like counts to templates
-		"getLikeCount": getLikeCount,
-	}
-
-	tpl = template.Must(template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/*.html"))
-
-	subStaticFS, err := fs.Sub(staticFS, "static")
-	if err != nil {
-		log.Fatalf("failed to prepare static filesystem: %v", err)
+		"getLikeCount":   getLikeCount,
+		"getRepostCount": getRepostCount,
+		"getReplyCount":  getReplyCount,
+		// follow button helpers
+		"followButton":     followButton,
+		"followersCountID": followersCountID,
 	}
 
-	http.HandleFunc("/", handleIndex)
-	http.HandleFunc("/signin", handleSignin)
-	http.HandleFunc("/post-status", handlePostStatus)
-	http.HandleFunc("/login", handleLogin)
-	http.HandleFunc("/logout", handleLogout)
-	http.HandleFunc("/oauth-callback", handleOAuthCallback)
-	http.HandleFunc("/oauth-client-metadata.json", handleClientMetadata)
-	http.HandleFunc("/timeline", handleTimeline)
-	http.HandleFunc("/timeline/post", handleTimelinePost)
-	http.HandleFunc("/post/", handlePost)
-	http.HandleFunc("/profile/", handleProfile)
-	http.HandleFunc("/reply", handleReply)
-	http.HandleFunc("/htmx/timeline", htmxTimelineFeed)
-	http.HandleFunc("/htmx/profile", htmxProfileFeed)
-	http.HandleFunc("/video/", handleVideo)
-	http.HandleFunc("/about", handleAbout)
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(subStaticFS))))
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Println("Listening on http://localhost:" + port)
-	log.Fatal(http.ListenAndServe(":"+port, loggingMiddleware(http.DefaultServeMux)))
+	return template.Must(template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/*.html"))
 }
@@ -28,9 +28,20 @@
         updateCharCount(max - ta.value.length);
       }
 
-      // Listen for HTMX afterRequest to reset the form
+      // Listen for HTMX afterRequest to reset the form. Requests issued from inside the form
+      // (e.g. the quote preview being swapped in) must not clear what the user is composing.
       form.addEventListener('htmx:afterRequest', function(evt){
-        try{ form.reset(); if (ta) updateCharCount(max); } catch(e){ console.log('form reset error', e); }
+        if (evt.detail && evt.detail.elt !== form) return;
+        // keep the draft (and attached images) when the server rejected it
+        if (evt.detail && !evt.detail.successful) return;
+        try{
+          form.reset();
+          if (ta) updateCharCount(max);
+          var quote = form.querySelector('.post-box-quote');
+          if (quote) quote.innerHTML = '';
+          var images = form.querySelector('.post-box-images');
+          if (images) images.open = false;
+        } catch(e){ console.log('form reset error', e); }
       });
     });
   }
@@ -174,6 +185,50 @@
     try{ window.removeEventListener('scroll', closeOpenReplyInput); window.removeEventListener('resize', closeOpenReplyInput); } catch(e){}
   }
 
+  // Submit an inline reply over htmx. On a post page the server answers with a thread node
+  // which is appended under the replied-to node (or at the top level when replying to the
+  // viewed post); elsewhere it answers with the updated reply button.
+  function submitReply(refEl, input, btn){
+    var replyTo = refEl.dataset && refEl.dataset.replyTo;
+    var text = input.value.trim();
+    if (!replyTo || text === '' || !window.htmx) return;
+
+    var values = { 'reply-to': replyTo, status: text };
+    var target, swap;
+    var threaded = document.getElementById('threaded-replies');
+    if (threaded){
+      values.mode = 'thread';
+      swap = 'beforeend';
+      var node = refEl.closest('.thread-node');
+      if (node){
+        var children = node.nextElementSibling;
+        if (!children || !children.classList.contains('thread-children')){
+          children = document.createElement('div');
+          children.className = 'thread-children';
+          node.parentNode.insertBefore(children, node.nextSibling);
+        }
+        target = children;
+      } else {
+        target = threaded;
+      }
+    } else {
+      values['class'] = refEl.dataset.class || '';
+      values.left = refEl.dataset.left || '';
+      target = refEl;
+      swap = 'outerHTML';
+    }
+
+    btn.disabled = true;
+    input.disabled = true;
+    htmx.ajax('POST', '/reply', { values: values, target: target, swap: swap }).then(function(){
+      closeOpenReplyInput();
+    }, function(err){
+      console.debug('reply submit error', err);
+      btn.disabled = false;
+      input.disabled = false;
+    });
+  }
+
   function createReplyInput(refEl, insertAfterEl){
     // refEl is typically the clicked reply-button element; insertAfterEl is optional contextual element
     if (!refEl) return null;
@@ -216,8 +271,10 @@
     btn.className = 'reply-submit';
     btn.type = 'button';
     btn.textContent = 'Reply';
-    // No-op click handler for now, keep a debug log
-    btn.addEventListener('click', function(ev){ ev.preventDefault(); try{ console.debug('Reply button clicked (no-op)'); } catch(e){} });
+    btn.addEventListener('click', function(ev){ ev.preventDefault(); submitReply(refEl, input, btn); });
+    input.addEventListener('keydown', function(ev){
+      if (ev.key === 'Enter'){ ev.preventDefault(); submitReply(refEl, input, btn); }
+    });
 
     container.appendChild(input);
     container.appendChild(btn);
@@ -292,6 +349,111 @@
     }, false);
   }
 
+  // Quote composer: the quote preview is swapped into the post box by htmx (see quote_button).
+  // Pages without a post box follow the button's link to the compose page instead.
+  // Focus the textarea once it arrives and let the user drop it again with the × button.
+  function initQuoteComposer(){
+    document.addEventListener('click', function(e){
+      var t = e.target;
+      if (!t || !t.closest) return;
+      var btn = t.closest('.qt-btn');
+      if (btn && document.getElementById('post-box-quote') && window.htmx) {
+        e.preventDefault();
+        htmx.ajax('GET', '/quote?uri=' + encodeURIComponent(btn.getAttribute('data-quote-uri')), {target: '#post-box-quote', swap: 'innerHTML'});
+        return;
+      }
+      var cancel = t.closest('.quote-cancel');
+      if (!cancel) return;
+      e.preventDefault();
+      var quote = cancel.closest('.post-box-quote');
+      if (quote) quote.innerHTML = '';
+    }, false);
+
+    document.body.addEventListener('htmx:afterSwap', function(evt){
+      var target = evt.detail && evt.detail.target;
+      if (!target || target.id !== 'post-box-quote') return;
+      var box = target.closest('.post-box');
+      var ta = box && box.querySelector('textarea');
+      try{
+        if (box) box.scrollIntoView({behavior: 'smooth', block: 'center'});
+        if (ta) ta.focus();
+      } catch(e){}
+    });
+  }
+
+  // Follow buttons: the same profile can have a follow button in several places on a page.
+  // Tell /follow which other copies, and whether the follower count, are present so the
+  // response only carries out-of-band swaps that have a target.
+  function initFollowButtons(){
+    document.body.addEventListener('htmx:configRequest', function(evt){
+      var elt = evt.detail && evt.detail.elt;
+      var box = elt && elt.closest && elt.closest('.follow-box');
+      if (!box || !elt.classList.contains('follow-btn')) return;
+      var did = box.getAttribute('data-did');
+      var places = [];
+      document.querySelectorAll('.follow-box').forEach(function(other){
+        if (other !== box && other.getAttribute('data-did') === did) places.push(other.getAttribute('data-place'));
+      });
+      evt.detail.parameters['places'] = places.join(',');
+      if (document.getElementById(box.getAttribute('data-count-id'))) evt.detail.parameters['count'] = '1';
+    });
+  }
+
+  // Handle typeahead: suggestions come from /htmx/typeahead into a .typeahead box whose
+  // data-for names its input. In a textarea the @word at the caret is completed (mentions),
+  // in a plain input the whole value is replaced (sign-in form).
+  function mentionAtCaret(ta){
+    var before = ta.value.slice(0, ta.selectionStart);
+    var m = before.match(/(^|\s)@([\w.-]*)$/);
+    if (!m) return null;
+    return { start: before.length - m[2].length - 1, end: ta.selectionStart, prefix: m[2] };
+  }
+
+  function initTypeahead(){
+    var timer = null;
+    document.addEventListener('input', function(e){
+      var ta = e.target;
+      if (!ta || ta.tagName !== 'TEXTAREA' || !ta.id) return;
+      var box = document.querySelector('.typeahead[data-for="' + ta.id + '"]');
+      if (!box) return;
+      clearTimeout(timer);
+      var mention = mentionAtCaret(ta);
+      if (!mention || !mention.prefix){ box.innerHTML = ''; return; }
+      timer = setTimeout(function(){
+        htmx.ajax('GET', '/htmx/typeahead?q=' + encodeURIComponent(mention.prefix), { target: box, swap: 'innerHTML' });
+      }, 250);
+    }, false);
+
+    document.addEventListener('click', function(e){
+      var t = e.target;
+      if (!t || !t.closest) return;
+      var item = t.closest('.typeahead-item');
+      var box = item && item.closest('.typeahead');
+      if (!box){
+        // clicking elsewhere dismisses any open suggestions
+        Array.prototype.forEach.call(document.querySelectorAll('.typeahead'), function(b){ b.innerHTML = ''; });
+        return;
+      }
+      e.preventDefault();
+      var input = document.getElementById(box.getAttribute('data-for'));
+      var handle = item.getAttribute('data-handle');
+      box.innerHTML = '';
+      if (!input || !handle) return;
+      if (input.tagName === 'TEXTAREA'){
+        var mention = mentionAtCaret(input);
+        if (!mention) return;
+        var text = '@' + handle + ' ';
+        input.value = input.value.slice(0, mention.start) + text + input.value.slice(mention.end);
+        var caret = mention.start + text.length;
+        input.setSelectionRange(caret, caret);
+        input.dispatchEvent(new Event('input', { bubbles: true }));
+      } else {
+        input.value = handle;
+      }
+      input.focus();
+    }, false);
+  }
+
   // On DOM ready
   document.addEventListener('DOMContentLoaded', function(){
     initLightbox();
@@ -308,6 +470,15 @@
 
     // initialize reply button behaviour
     initReplyButtons();
+
+    // quote composer wiring
+    initQuoteComposer();
+
+    // @handle suggestions in the post box and sign-in form
+    initTypeahead();
+
+    // out-of-band updates of other follow buttons for the same profile
+    initFollowButtons();
   });
 
   // expose initPostPage for compatibility with small inline stub
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+)
+
+const (
+	// timelinePageSize is how many timeline items a page aims for, filtered or not.
+	timelinePageSize = 50
+	// maxTimelineFetches bounds the getTimeline calls made to fill one filtered page.
+	maxTimelineFetches = 5
+)
+
+// TimelineFilters are the per-user timeline settings, stored in sqlite keyed by DID.
+type TimelineFilters struct {
+	HideReposts              bool `json:"hideReposts"`
+	HideRepliesToNonFollowed bool `json:"hideRepliesToNonFollowed"`
+	HideQuotes               bool `json:"hideQuotes"`
+	HideNoText               bool `json:"hideNoText"`
+}
+
+// Active reports whether any filter is on.
+func (f TimelineFilters) Active() bool {
+	return f.HideReposts || f.HideRepliesToNonFollowed || f.HideQuotes || f.HideNoText
+}
+
+// Keep reports whether a timeline item passes the filters. viewerDid is the signed-in user,
+// whose own replies are always kept.
+func (f TimelineFilters) Keep(fv *bsky.FeedDefs_FeedViewPost, viewerDid string) bool {
+	if fv == nil || fv.Post == nil {
+		return false
+	}
+	switch GetPostType(fv) {
+	case PostTypeRetweet:
+		if f.HideReposts {
+			return false
+		}
+	case PostTypeQuote:
+		if f.HideQuotes {
+			return false
+		}
+	}
+	if f.HideNoText && strings.TrimSpace(getPostText(fv.Post.Record)) == "" {
+		return false
+	}
+	if f.HideRepliesToNonFollowed && fv.Reply != nil && !replyToFollowed(fv.Reply, viewerDid) {
+		return false
+	}
+	return true
+}
+
+// replyToFollowed reports whether a reply's parent was written by the viewer or someone they
+// follow. Parents that are deleted or blocked count as not followed.
+func replyToFollowed(reply *bsky.FeedDefs_ReplyRef, viewerDid string) bool {
+	if reply.Parent == nil || reply.Parent.FeedDefs_PostView == nil || reply.Parent.FeedDefs_PostView.Author == nil {
+		return false
+	}
+	author := reply.Parent.FeedDefs_PostView.Author
+	if author.Did == viewerDid {
+		return true
+	}
+	return author.Viewer != nil && author.Viewer.Following != nil && *author.Viewer.Following != ""
+}
+
+// loadTimelineFilters returns did's timeline filters; errors are logged and mean no filtering.
+func loadTimelineFilters(ctx context.Context, did string) TimelineFilters {
+	if settingsStore == nil {
+		return TimelineFilters{}
+	}
+	f, err := settingsStore.GetTimelineFilters(ctx, did)
+	if err != nil {
+		log.Printf("DEBUG: loadTimelineFilters - error loading filters for %s: %v", did, err)
+		return TimelineFilters{}
+	}
+	return f
+}
+
+// fetchTimeline loads a page of the home timeline with the user's filters applied. When the
+// filters drop items, it keeps following the cursor until the page is full, the timeline
+// ends or maxTimelineFetches calls were made. Each follow-up call only asks for the items
+// still missing, so a page never holds more than timelinePageSize items and the returned
+// cursor continues right after the last fetched one.
+func fetchTimeline(ctx context.Context, c *client.APIClient, did, cursor string) (*bsky.FeedGetTimeline_Output, error) {
+	filters := loadTimelineFilters(ctx, did)
+	timeline, err := bsky.FeedGetTimeline(ctx, c, "", cursor, timelinePageSize)
+	if err != nil || !filters.Active() {
+		return timeline, err
+	}
+
+	out := &bsky.FeedGetTimeline_Output{Cursor: timeline.Cursor}
+	for fetches := 1; ; fetches++ {
+		for _, fv := range timeline.Feed {
+			if filters.Keep(fv, did) {
+				out.Feed = append(out.Feed, fv)
+			}
+		}
+		out.Cursor = timeline.Cursor
+		if len(out.Feed) >= timelinePageSize || timeline.Cursor == nil || *timeline.Cursor == "" || fetches >= maxTimelineFetches {
+			break
+		}
+		timeline, err = bsky.FeedGetTimeline(ctx, c, "", *timeline.Cursor, int64(timelinePageSize-len(out.Feed)))
+		if err != nil {
+			// serve what was collected; the cursor still points at the failed page
+			log.Printf("DEBUG: fetchTimeline - getTimeline error while filling page: %v", err)
+			break
+		}
+	}
+	return out, nil
+}
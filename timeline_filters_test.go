@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+func timelineItem(text string) *bsky.FeedDefs_FeedViewPost {
+	return &bsky.FeedDefs_FeedViewPost{Post: &bsky.FeedDefs_PostView{
+		Uri:    "at://did:plc:author/app.bsky.feed.post/1",
+		Author: &bsky.ActorDefs_ProfileViewBasic{Did: "did:plc:author", Handle: "author.test"},
+		Record: &util.LexiconTypeDecoder{Val: &bsky.FeedPost{Text: text}},
+	}}
+}
+
+// replyTo makes fv a reply to a post by parentDid; following is the viewer's follow of them.
+func replyTo(fv *bsky.FeedDefs_FeedViewPost, parentDid string, following bool) *bsky.FeedDefs_FeedViewPost {
+	author := &bsky.ActorDefs_ProfileViewBasic{Did: parentDid, Handle: "parent.test", Viewer: &bsky.ActorDefs_ViewerState{}}
+	if following {
+		follow := "at://" + testDID + "/app.bsky.graph.follow/1"
+		author.Viewer.Following = &follow
+	}
+	parent := &bsky.FeedDefs_ReplyRef_Parent{FeedDefs_PostView: &bsky.FeedDefs_PostView{Uri: "at://" + parentDid + "/app.bsky.feed.post/p", Author: author}}
+	fv.Reply = &bsky.FeedDefs_ReplyRef{Parent: parent, Root: &bsky.FeedDefs_ReplyRef_Root{FeedDefs_PostView: parent.FeedDefs_PostView}}
+	return fv
+}
+
+func TestTimelineFiltersKeep(t *testing.T) {
+	repost := timelineItem("reposted")
+	repost.Reason = &bsky.FeedDefs_FeedViewPost_Reason{FeedDefs_ReasonRepost: &bsky.FeedDefs_ReasonRepost{
+		By: &bsky.ActorDefs_ProfileViewBasic{Did: "did:plc:friend", Handle: "friend.test"},
+	}}
+	quote := timelineItem("quoting")
+	quote.Post.Embed = &bsky.FeedDefs_PostView_Embed{EmbedRecord_View: &bsky.EmbedRecord_View{}}
+	deletedParent := timelineItem("reply")
+	deletedParent.Reply = &bsky.FeedDefs_ReplyRef{Parent: &bsky.FeedDefs_ReplyRef_Parent{
+		FeedDefs_NotFoundPost: &bsky.FeedDefs_NotFoundPost{Uri: "at://did:plc:gone/app.bsky.feed.post/p", NotFound: true},
+	}}
+	all := TimelineFilters{HideReposts: true, HideRepliesToNonFollowed: true, HideQuotes: true, HideNoText: true}
+
+	tests := []struct {
+		name    string
+		filters TimelineFilters
+		item    *bsky.FeedDefs_FeedViewPost
+		want    bool
+	}{
+		{"post", all, timelineItem("hello"), true},
+		{"no post", TimelineFilters{}, &bsky.FeedDefs_FeedViewPost{}, false},
+		{"repost hidden", TimelineFilters{HideReposts: true}, repost, false},
+		{"repost shown", TimelineFilters{HideQuotes: true}, repost, true},
+		{"quote hidden", TimelineFilters{HideQuotes: true}, quote, false},
+		{"quote shown", TimelineFilters{HideReposts: true}, quote, true},
+		{"no text hidden", TimelineFilters{HideNoText: true}, timelineItem(" \n "), false},
+		{"no text shown", TimelineFilters{HideReposts: true}, timelineItem(""), true},
+		{"reply to non-followed hidden", TimelineFilters{HideRepliesToNonFollowed: true}, replyTo(timelineItem("reply"), "did:plc:stranger", false), false},
+		{"reply to non-followed shown", TimelineFilters{HideNoText: true}, replyTo(timelineItem("reply"), "did:plc:stranger", false), true},
+		{"reply to followed", TimelineFilters{HideRepliesToNonFollowed: true}, replyTo(timelineItem("reply"), "did:plc:friend", true), true},
+		{"reply to own post", TimelineFilters{HideRepliesToNonFollowed: true}, replyTo(timelineItem("reply"), testDID, false), true},
+		{"reply to deleted parent", TimelineFilters{HideRepliesToNonFollowed: true}, deletedParent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filters.Keep(tt.item, testDID); got != tt.want {
+				t.Errorf("Keep = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// useTimelineFilters saves filters for testDID in a temporary settings store.
+func useTimelineFilters(t *testing.T, filters TimelineFilters) {
+	t.Helper()
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "settings.db"), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveTimelineFilters(context.Background(), testDID, filters); err != nil {
+		t.Fatal(err)
+	}
+	prev := settingsStore
+	settingsStore = s
+	t.Cleanup(func() { settingsStore = prev })
+}
+
+func TestFetchTimeline(t *testing.T) {
+	tests := []struct {
+		name       string
+		length     int              // items in the whole timeline
+		hasText    func(i int) bool // which items pass HideNoText
+		wantLimits []int            // each call only asks for the items still missing
+		wantLen    int
+		wantNext   string
+	}{
+		{name: "fills the page", length: 1000, hasText: func(i int) bool { return i >= 10 }, wantLimits: []int{50, 10}, wantLen: 50, wantNext: "60"},
+		{name: "stops at maxTimelineFetches", length: 1000, hasText: func(i int) bool { return i%10 == 0 }, wantLimits: []int{50, 45, 40, 36, 32}, wantLen: 21, wantNext: "203"},
+		{name: "timeline ends", length: 70, hasText: func(i int) bool { return i%2 == 0 }, wantLimits: []int{50, 25}, wantLen: 35},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTimelineFilters(t, TimelineFilters{HideNoText: true})
+			var limits []int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/xrpc/app.bsky.feed.getTimeline" {
+					http.NotFound(w, r)
+					return
+				}
+				start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				limits = append(limits, limit)
+				end := min(start+limit, tt.length)
+				feed := []map[string]any{}
+				for i := start; i < end; i++ {
+					text := ""
+					if tt.hasText(i) {
+						text = "post " + strconv.Itoa(i)
+					}
+					feed = append(feed, map[string]any{"post": map[string]any{
+						"uri":       fmt.Sprintf("at://did:plc:author/app.bsky.feed.post/%d", i),
+						"cid":       "bafyreib2rxk3rh6kzwq",
+						"author":    map[string]any{"did": "did:plc:author", "handle": "author.test"},
+						"record":    map[string]any{"$type": "app.bsky.feed.post", "text": text, "createdAt": "2025-01-01T00:00:00Z"},
+						"indexedAt": "2025-01-01T00:00:00Z",
+					}})
+				}
+				out := map[string]any{"feed": feed}
+				if end < tt.length {
+					out["cursor"] = strconv.Itoa(end)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(out)
+			}))
+			defer srv.Close()
+
+			timeline, err := fetchTimeline(context.Background(), client.NewAPIClient(srv.URL), testDID, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(limits, tt.wantLimits) {
+				t.Errorf("getTimeline limits = %v, want %v", limits, tt.wantLimits)
+			}
+			if len(timeline.Feed) != tt.wantLen {
+				t.Errorf("page has %d items, want %d", len(timeline.Feed), tt.wantLen)
+			}
+			for _, fv := range timeline.Feed {
+				if getPostText(fv.Post.Record) == "" {
+					t.Errorf("%s has no text but was kept", fv.Post.Uri)
+				}
+			}
+			next := ""
+			if timeline.Cursor != nil {
+				next = *timeline.Cursor
+			}
+			if next != tt.wantNext {
+				t.Errorf("cursor = %q, want %q", next, tt.wantNext)
+			}
+		})
+	}
+}
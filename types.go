@@ -11,6 +11,10 @@ type PostStatusPageData struct {
 	CurrentUser *bsky.ActorDefs_ProfileViewDetailed
 	Profile     *bsky.ActorDefs_ProfileViewDetailed
 	Follows     []*bsky.ActorDefs_ProfileView
+	ErrorMsg    string
+	StatusMsg   string
+	// Quote is the post being quoted when the page was opened from a Quote button
+	Quote *bsky.FeedDefs_PostView
 	// SignedIn is the currently signed-in profile (typed, may be nil)
 	SignedIn *bsky.ActorDefs_ProfileViewDetailed
 }
@@ -23,10 +27,20 @@ type TimelinePageData struct {
 	Follows       []*bsky.ActorDefs_ProfileView
 	Posts         PostsList
 	PostBoxHandle string
+	// Tabs are the home timeline and pinned feed tabs
+	Tabs []FeedTab
 	// SignedIn is the currently signed-in profile (typed, may be nil)
 	SignedIn *bsky.ActorDefs_ProfileViewDetailed
 }
 
+type TimelineFiltersPageData struct {
+	Title    string
+	Profile  *bsky.ActorDefs_ProfileViewDetailed
+	Follows  []*bsky.ActorDefs_ProfileView
+	Filters  TimelineFilters
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
 type TimelinePartialData struct {
 	Timeline *bsky.FeedGetTimeline_Output
 	Posts    PostsList
@@ -38,7 +52,7 @@ type PostPageData struct {
 	Title             string
 	Post              *bsky.FeedDefs_PostView
 	Replies           []*bsky.FeedDefs_PostView
-	ParentChain       []*bsky.FeedDefs_PostView
+	ParentChain       []ThreadItem
 	ViewedURI         string
 	ThreadRoot        *bsky.FeedDefs_ThreadViewPost
 	CurrentUser       *bsky.ActorDefs_ProfileViewDetailed
@@ -48,13 +62,138 @@ type PostPageData struct {
 	SignedIn *bsky.ActorDefs_ProfileViewDetailed
 }
 
+// SignedInDid is the DID of the signed-in user, or "" when signed out.
+func (d PostPageData) SignedInDid() string {
+	if d.SignedIn == nil {
+		return ""
+	}
+	return d.SignedIn.Did
+}
+
 type ProfilePageData struct {
+	Title    string
+	ErrorMsg string
+	Profile  *bsky.ActorDefs_ProfileViewDetailed
+	Feed     *bsky.FeedGetAuthorFeed_Output
+	Follows  []*bsky.ActorDefs_ProfileView
+	// Tab is the selected profile tab (see profileTabs)
+	Tab string
+	// Pinned is the profile's pinned post, shown above the updates tab (may be nil)
+	Pinned        *bsky.FeedDefs_FeedViewPost
+	Posts         PostsList
+	PostBoxHandle string
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type ConnectionsPageData struct {
+	Title string
+	// Kind is "following" or "followers"
+	Kind    string
+	Profile *bsky.ActorDefs_ProfileViewDetailed
+	Follows []*bsky.ActorDefs_ProfileView
+	People  ActorsList
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type FavoritesPageData struct {
+	Title    string
+	ErrorMsg string
+	Profile  *bsky.ActorDefs_ProfileViewDetailed
+	Follows  []*bsky.ActorDefs_ProfileView
+	Posts    PostsList
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type NotificationsPageData struct {
 	Title         string
 	Profile       *bsky.ActorDefs_ProfileViewDetailed
-	Feed          *bsky.FeedGetAuthorFeed_Output
 	Follows       []*bsky.ActorDefs_ProfileView
-	Posts         PostsList
-	PostBoxHandle string
+	Notifications NotificationsList
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type SearchPageData struct {
+	Title    string
+	Query    string
+	Sort     string
+	ErrorMsg string
+	Profile  *bsky.ActorDefs_ProfileViewDetailed
+	Follows  []*bsky.ActorDefs_ProfileView
+	Posts    PostsList
+	People   ActorsList
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type TagPageData struct {
+	Title string
+	Tag   string
+	// Count is the number of recent posts with the tag, as shown in the page header
+	Count    string
+	ErrorMsg string
+	Profile  *bsky.ActorDefs_ProfileViewDetailed
+	Follows  []*bsky.ActorDefs_ProfileView
+	Posts    PostsList
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type FeedPageData struct {
+	Title     string
+	ErrorMsg  string
+	Profile   *bsky.ActorDefs_ProfileViewDetailed
+	Follows   []*bsky.ActorDefs_ProfileView
+	Generator *bsky.FeedDefs_GeneratorView
+	Controls  FeedControlsVM
+	Tabs      []FeedTab
+	Posts     PostsList
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+// FeedItemVM is a feed generator row of the feed picker.
+type FeedItemVM struct {
+	Generator *bsky.FeedDefs_GeneratorView
+	Controls  FeedControlsVM
+}
+
+type FeedsPageData struct {
+	Title     string
+	ErrorMsg  string
+	Profile   *bsky.ActorDefs_ProfileViewDetailed
+	Follows   []*bsky.ActorDefs_ProfileView
+	Saved     []FeedItemVM
+	Suggested []FeedItemVM
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type ListsPageData struct {
+	Title    string
+	ErrorMsg string
+	Profile  *bsky.ActorDefs_ProfileViewDetailed
+	Follows  []*bsky.ActorDefs_ProfileView
+	Lists    []*bsky.GraphDefs_ListView
+	// SignedIn is the currently signed-in profile (typed, may be nil)
+	SignedIn *bsky.ActorDefs_ProfileViewDetailed
+}
+
+type ListPageData struct {
+	Title    string
+	ErrorMsg string
+	// View is "updates" (the list timeline) or "members"
+	View    string
+	Profile *bsky.ActorDefs_ProfileViewDetailed
+	Follows []*bsky.ActorDefs_ProfileView
+	List    *bsky.GraphDefs_ListView
+	// Owner is set when the signed-in user created the list
+	Owner   bool
+	Posts   PostsList
+	Members ListMembersVM
 	// SignedIn is the currently signed-in profile (typed, may be nil)
 	SignedIn *bsky.ActorDefs_ProfileViewDetailed
 }
@@ -86,4 +225,4 @@ func (p AuthorProvider) Posts() []*bsky.FeedDefs_FeedViewPost {
 	return p.F.Feed
 }
 
-func (p AuthorProvider) Cursor() string { return "" }
+func (p AuthorProvider) Cursor() string { return getCursorFromAuthorFeed(p.F) }
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/atproto"
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/lex/util"
+)
+
+const (
+	// defaultVideoHost is Bluesky's app.bsky.video service. Override with BSKY_VIDEO_SERVICE.
+	defaultVideoHost = "https://video.bsky.app"
+	// maxVideoBytes is the largest video app.bsky.embed.video accepts.
+	maxVideoBytes = 100 << 20
+	// maxCaptionBytes is the largest caption file app.bsky.embed.video accepts.
+	maxCaptionBytes = 20000
+	// maxVideoStatusFailures is how many status polls in a row may fail before the post box
+	// stops polling and reports an error.
+	maxVideoStatusFailures = 5
+
+	videoJobCompleted = "JOB_STATE_COMPLETED"
+	videoJobFailed    = "JOB_STATE_FAILED"
+)
+
+// allowedVideoTypes lists the sniffed MIME types accepted for video uploads.
+var allowedVideoTypes = map[string]bool{
+	"video/mp4":  true,
+	"video/webm": true,
+}
+
+// VideoService transcodes uploaded videos and reports job progress. It mirrors the
+// app.bsky.video endpoints so a local fake can stand in for the real service.
+type VideoService interface {
+	// UploadVideo starts a processing job for the video on behalf of the session's account.
+	UploadVideo(ctx context.Context, c *client.APIClient, did, name, mimeType string, video io.Reader) (*bsky.VideoDefs_JobStatus, error)
+	// GetJobStatus reports the state of a job; completed jobs carry the uploaded blob.
+	GetJobStatus(ctx context.Context, jobID string) (*bsky.VideoDefs_JobStatus, error)
+}
+
+// videoService is the VideoService used by the compose handlers.
+var videoService VideoService = &bskyVideoService{Host: defaultVideoHost}
+
+// bskyVideoService talks to an app.bsky.video host. Uploads are authorized with a service
+// auth token minted by the user's PDS, since the video service writes the processed blob
+// back to that PDS.
+type bskyVideoService struct {
+	Host string
+}
+
+func (s *bskyVideoService) UploadVideo(ctx context.Context, c *client.APIClient, did, name, mimeType string, video io.Reader) (*bsky.VideoDefs_JobStatus, error) {
+	pds, err := url.Parse(c.Host)
+	if err != nil || pds.Hostname() == "" {
+		return nil, fmt.Errorf("unable to determine PDS host from %q", c.Host)
+	}
+	auth, err := atproto.ServerGetServiceAuth(ctx, c, "did:web:"+pds.Hostname(), time.Now().Add(30*time.Minute).Unix(), "com.atproto.repo.uploadBlob")
+	if err != nil {
+		return nil, fmt.Errorf("getServiceAuth error: %w", err)
+	}
+
+	vc := client.NewAPIClient(s.Host)
+	vc.Headers.Set("Authorization", "Bearer "+auth.Token)
+	var out bsky.VideoUploadVideo_Output
+	params := map[string]any{"did": did, "name": name}
+	if err := vc.LexDo(ctx, util.Procedure, mimeType, "app.bsky.video.uploadVideo", params, video, &out); err != nil {
+		return nil, fmt.Errorf("uploadVideo error: %w", err)
+	}
+	if out.JobStatus == nil {
+		return nil, fmt.Errorf("uploadVideo returned no job")
+	}
+	return out.JobStatus, nil
+}
+
+func (s *bskyVideoService) GetJobStatus(ctx context.Context, jobID string) (*bsky.VideoDefs_JobStatus, error) {
+	out, err := bsky.VideoGetJobStatus(ctx, client.NewAPIClient(s.Host), jobID)
+	if err != nil {
+		return nil, fmt.Errorf("getJobStatus error: %w", err)
+	}
+	if out.JobStatus == nil {
+		return nil, fmt.Errorf("getJobStatus returned no job")
+	}
+	return out.JobStatus, nil
+}
+
+// VideoStatusVM is the view model of the post box video slot while a video is processed.
+type VideoStatusVM struct {
+	JobID    string
+	State    string
+	Progress int64
+	Error    string
+	// Failures counts consecutive failed status polls; it's echoed back on the next poll.
+	Failures int
+	// Blob is the JSON-encoded blob of a completed job, carried in the form until the post is made.
+	Blob string
+	// OOB renders the slot as an htmx out-of-band swap (used to reset it after posting).
+	OOB bool
+}
+
+// Done reports whether the video finished processing and can be posted.
+func (v VideoStatusVM) Done() bool { return v.Blob != "" }
+
+// newVideoStatusVM converts a job status into the post box view model.
+func newVideoStatusVM(job *bsky.VideoDefs_JobStatus) (VideoStatusVM, error) {
+	vm := VideoStatusVM{JobID: job.JobId, State: job.State}
+	if job.Progress != nil {
+		vm.Progress = *job.Progress
+	}
+	switch {
+	case job.Error != nil && *job.Error != "":
+		vm.Error = *job.Error
+	case job.State == videoJobFailed:
+		vm.Error = "video processing failed"
+		if job.Message != nil && *job.Message != "" {
+			vm.Error = *job.Message
+		}
+	case job.State == videoJobCompleted && job.Blob != nil:
+		b, err := json.Marshal(job.Blob)
+		if err != nil {
+			return vm, err
+		}
+		vm.Blob = string(b)
+	}
+	return vm, nil
+}
+
+// videoEmbedFromForm builds the app.bsky.embed.video for a post from the processed blob kept in
+// the post box (video-blob), its alt text and an optional WebVTT caption file. Returns nil when
+// no video was attached.
+func videoEmbedFromForm(ctx context.Context, c *client.APIClient, r *http.Request) (*bsky.EmbedVideo, error) {
+	raw := r.FormValue("video-blob")
+	if raw == "" {
+		return nil, nil
+	}
+	var blob util.LexBlob
+	if err := json.Unmarshal([]byte(raw), &blob); err != nil {
+		return nil, fmt.Errorf("invalid video blob: %w", err)
+	}
+	embed := &bsky.EmbedVideo{Video: &blob}
+	if alt := strings.TrimSpace(r.FormValue("video-alt")); alt != "" {
+		embed.Alt = &alt
+	}
+
+	caption, err := readCaptionUpload(r)
+	if err != nil {
+		return nil, err
+	}
+	if caption != nil {
+		lang := strings.TrimSpace(r.FormValue("caption-lang"))
+		if lang == "" {
+			lang = "en"
+		}
+		out, err := uploadBlobWithType(ctx, c, "text/vtt", bytes.NewReader(caption))
+		if err != nil {
+			return nil, err
+		}
+		embed.Captions = []*bsky.EmbedVideo_Caption{{File: out, Lang: lang}}
+	}
+	return embed, nil
+}
+
+// readCaptionUpload returns the contents of the caption file input, if any.
+func readCaptionUpload(r *http.Request) ([]byte, error) {
+	if r.MultipartForm == nil || len(r.MultipartForm.File["caption"]) == 0 {
+		return nil, nil
+	}
+	fh := r.MultipartForm.File["caption"][0]
+	if fh.Size == 0 {
+		return nil, nil
+	}
+	if fh.Size > maxCaptionBytes {
+		return nil, fmt.Errorf("%s is too large (max %d KB)", fh.Filename, maxCaptionBytes/1000)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxCaptionBytes))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(strings.TrimPrefix(string(data), "\ufeff"), "WEBVTT") {
+		return nil, fmt.Errorf("%s is not a WebVTT caption file", fh.Filename)
+	}
+	return data, nil
+}
+
+// uploadBlobWithType uploads a blob with an explicit Content-Type. RepoUploadBlob always sends
+// */*, which leaves non-media types such as captions to the PDS's content sniffing.
+func uploadBlobWithType(ctx context.Context, c *client.APIClient, mimeType string, body io.Reader) (*util.LexBlob, error) {
+	var out atproto.RepoUploadBlob_Output
+	if err := c.LexDo(ctx, util.Procedure, mimeType, "com.atproto.repo.uploadBlob", nil, body, &out); err != nil {
+		return nil, fmt.Errorf("uploadBlob error: %w", err)
+	}
+	return out.Blob, nil
+}
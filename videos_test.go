@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	bsky "github.com/bluesky-social/indigo/api/bsky"
+	"github.com/bluesky-social/indigo/atproto/auth/oauth"
+	"github.com/bluesky-social/indigo/atproto/client"
+	"github.com/bluesky-social/indigo/atproto/crypto"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/bluesky-social/indigo/lex/util"
+	"github.com/gorilla/sessions"
+)
+
+const testDID = "did:plc:testuser"
+
+var setupHandlersOnce sync.Once
+
+// setupHandlers initializes the globals the handlers rely on: templates, the cookie store and
+// an OAuth app holding one resumable session for testDID.
+func setupHandlers(t *testing.T) {
+	t.Helper()
+	setupHandlersOnce.Do(func() {
+		tpl = parseTemplates()
+		store = sessions.NewCookieStore([]byte("test-session-secret"))
+		config := oauth.NewPublicConfig("", "", []string{"atproto"})
+		oauthApp = oauth.NewClientApp(&config, oauth.NewMemStore())
+		key, err := crypto.GeneratePrivateKeyP256()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = oauthApp.Store.SaveSession(context.Background(), oauth.ClientSessionData{
+			AccountDID:              syntax.DID(testDID),
+			SessionID:               "test-session",
+			HostURL:                 "https://pds.invalid",
+			DPoPPrivateKeyMultibase: key.Multibase(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// signedIn adds the session cookie of testDID to r.
+func signedIn(t *testing.T, r *http.Request) *http.Request {
+	t.Helper()
+	setupHandlers(t)
+	rec := httptest.NewRecorder()
+	session, _ := store.Get(httptest.NewRequest(http.MethodGet, "/", nil), sessionName)
+	session.Values["did"] = testDID
+	session.Values["session_id"] = "test-session"
+	if err := session.Save(r, rec); err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+// fakeVideoService is an in-memory VideoService. Uploads create jobs in JOB_STATE_CREATED;
+// tests move them along by editing jobs.
+type fakeVideoService struct {
+	mu        sync.Mutex
+	jobs      map[string]*bsky.VideoDefs_JobStatus
+	uploads   []string
+	uploadErr error
+	statusErr error
+}
+
+func (f *fakeVideoService) UploadVideo(ctx context.Context, c *client.APIClient, did, name, mimeType string, video io.Reader) (*bsky.VideoDefs_JobStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.uploadErr != nil {
+		return nil, f.uploadErr
+	}
+	if _, err := io.ReadAll(video); err != nil {
+		return nil, err
+	}
+	f.uploads = append(f.uploads, name+" "+mimeType)
+	job := &bsky.VideoDefs_JobStatus{JobId: "job-1", Did: did, State: "JOB_STATE_CREATED"}
+	if f.jobs == nil {
+		f.jobs = map[string]*bsky.VideoDefs_JobStatus{}
+	}
+	f.jobs[job.JobId] = job
+	return job, nil
+}
+
+func (f *fakeVideoService) GetJobStatus(ctx context.Context, jobID string) (*bsky.VideoDefs_JobStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.statusErr != nil {
+		return nil, f.statusErr
+	}
+	job, ok := f.jobs[jobID]
+	if !ok {
+		return nil, errors.New("job not found")
+	}
+	return job, nil
+}
+
+// useVideoService swaps in a fake for the duration of the test.
+func useVideoService(t *testing.T, svc VideoService) {
+	prev := videoService
+	videoService = svc
+	t.Cleanup(func() { videoService = prev })
+}
+
+// mp4Header is enough of an MP4 for http.DetectContentType to sniff video/mp4.
+var mp4Header = []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+
+func videoUploadRequest(t *testing.T, name string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("video", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/compose/video", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return signedIn(t, r)
+}
+
+func TestHandleComposeVideo(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		data      []byte
+		uploadErr error
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:    "uploading starts polling",
+			file:    "clip.mp4",
+			data:    mp4Header,
+			want:    []string{`hx-get="/compose/video/status?job=job-1"`, `hx-trigger="every 2s"`, "processing video"},
+			notWant: []string{"error-text"},
+		},
+		{
+			name:    "unsupported type",
+			file:    "notes.txt",
+			data:    []byte("just some text"),
+			want:    []string{"unsupported video type text/plain", `name="video"`},
+			notWant: []string{"every 2s"},
+		},
+		{
+			name:      "upload failure",
+			file:      "clip.mp4",
+			data:      mp4Header,
+			uploadErr: errors.New("boom"),
+			want:      []string{"Video upload failed", `name="video"`},
+			notWant:   []string{"every 2s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeVideoService{uploadErr: tt.uploadErr}
+			useVideoService(t, svc)
+			rec := httptest.NewRecorder()
+			handleComposeVideo(rec, videoUploadRequest(t, tt.file, tt.data))
+			assertVideoSlot(t, rec, tt.want, tt.notWant)
+		})
+	}
+
+	t.Run("signed out", func(t *testing.T) {
+		setupHandlers(t)
+		rec := httptest.NewRecorder()
+		handleComposeVideo(rec, httptest.NewRequest(http.MethodPost, "/compose/video", nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+	})
+}
+
+func TestHandleComposeVideoStatus(t *testing.T) {
+	progress := int64(40)
+	failed := "unsupported codec"
+	var blob util.LexBlob
+	if err := json.Unmarshal([]byte(`{"$type":"blob","ref":{"$link":"bafkreibme22gw2h7y2h7tg2fhqotaqjucnbc24deqo72b6mkl2egezxhvy"},"mimeType":"video/mp4","size":1234}`), &blob); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		job       *bsky.VideoDefs_JobStatus
+		statusErr error
+		query     string
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:    "processing",
+			job:     &bsky.VideoDefs_JobStatus{JobId: "job-1", State: "JOB_STATE_ENCODING", Progress: &progress},
+			want:    []string{`hx-trigger="every 2s"`, "processing video (40%)"},
+			notWant: []string{"video-blob", "error-text"},
+		},
+		{
+			name:    "completed",
+			job:     &bsky.VideoDefs_JobStatus{JobId: "job-1", State: videoJobCompleted, Blob: &blob},
+			want:    []string{"video ready", `name="video-blob"`, "bafkreibme22gw2h7y2h7tg2fhqotaqjucnbc24deqo72b6mkl2egezxhvy", `name="video-alt"`},
+			notWant: []string{"every 2s"},
+		},
+		{
+			name:    "failed",
+			job:     &bsky.VideoDefs_JobStatus{JobId: "job-1", State: videoJobFailed, Message: &failed},
+			want:    []string{"unsupported codec", `name="video"`},
+			notWant: []string{"every 2s", "video-blob"},
+		},
+		{
+			name:      "transient error keeps polling",
+			statusErr: errors.New("unavailable"),
+			want:      []string{`hx-get="/compose/video/status?job=job-1&failures=1"`, `hx-trigger="every 2s"`},
+			notWant:   []string{"error-text"},
+		},
+		{
+			name:      "repeated errors stop polling",
+			statusErr: errors.New("unavailable"),
+			query:     "&failures=4",
+			want:      []string{"Couldn&#39;t check on the video", `name="video"`},
+			notWant:   []string{"every 2s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeVideoService{statusErr: tt.statusErr}
+			if tt.job != nil {
+				svc.jobs = map[string]*bsky.VideoDefs_JobStatus{tt.job.JobId: tt.job}
+			}
+			useVideoService(t, svc)
+			rec := httptest.NewRecorder()
+			handleComposeVideoStatus(rec, signedIn(t, httptest.NewRequest(http.MethodGet, "/compose/video/status?job=job-1"+tt.query, nil)))
+			assertVideoSlot(t, rec, tt.want, tt.notWant)
+		})
+	}
+}
+
+func TestComposeVideoFlow(t *testing.T) {
+	svc := &fakeVideoService{}
+	useVideoService(t, svc)
+	handleComposeVideo(httptest.NewRecorder(), videoUploadRequest(t, "clip.mp4", mp4Header))
+	if len(svc.uploads) != 1 || svc.uploads[0] != "clip.mp4 video/mp4" {
+		t.Fatalf("uploads = %v", svc.uploads)
+	}
+
+	poll := func() string {
+		rec := httptest.NewRecorder()
+		handleComposeVideoStatus(rec, signedIn(t, httptest.NewRequest(http.MethodGet, "/compose/video/status?job=job-1", nil)))
+		return rec.Body.String()
+	}
+	if body := poll(); !strings.Contains(body, "processing video") {
+		t.Errorf("created job: %s", body)
+	}
+	svc.mu.Lock()
+	svc.jobs["job-1"].State = videoJobFailed
+	svc.mu.Unlock()
+	if body := poll(); !strings.Contains(body, "video processing failed") || strings.Contains(body, "every 2s") {
+		t.Errorf("failed job: %s", body)
+	}
+}
+
+func assertVideoSlot(t *testing.T, rec *httptest.ResponseRecorder, want, notWant []string) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, s := range want {
+		if !strings.Contains(body, s) {
+			t.Errorf("missing %q in\n%s", s, body)
+		}
+	}
+	for _, s := range notWant {
+		if strings.Contains(body, s) {
+			t.Errorf("unexpected %q in\n%s", s, body)
+		}
+	}
+}